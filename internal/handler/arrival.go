@@ -37,8 +37,7 @@ func (h *BusArrival) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	arrivals, err := h.lta.GetBusArrival(r.Context(), busStopCode, serviceNo)
 	if err != nil {
 		slog.Error("Error getting bus arrival from LTA API", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch arrival data"})
+		writeUpstreamError(w, err, "Failed to fetch arrival data")
 		return
 	}
 
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBatchStops caps how many stops a single batch request may ask for.
+	maxBatchStops = 20
+	// batchConcurrency bounds the number of in-flight upstream calls per batch.
+	batchConcurrency = 4
+	// maxBatchBodyBytes bounds the request body, comfortably above
+	// maxBatchStops stops with service filters.
+	maxBatchBodyBytes = 8 << 10
+)
+
+// BatchArrivals serves arrivals for several stops in one request, so
+// dashboards don't need one round trip per stop.
+type BatchArrivals struct {
+	lta LTAClient
+}
+
+func NewBatchArrivals(client LTAClient) *BatchArrivals {
+	return &BatchArrivals{lta: client}
+}
+
+type batchStopReq struct {
+	Code     string   `json:"code"`
+	Services []string `json:"services,omitempty"`
+}
+
+type batchReq struct {
+	Stops []batchStopReq `json:"stops"`
+}
+
+// BatchStopResult is the arrival data for one stop in a batch. Error is set
+// when that stop could not be fetched; the other stops are unaffected.
+type BatchStopResult struct {
+	StopArrivalResponse
+	Error string `json:"error,omitempty"`
+}
+
+type BatchArrivalResponse struct {
+	Results []BatchStopResult `json:"results"`
+}
+
+func (h *BatchArrivals) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+	var req batchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		return
+	}
+	if len(req.Stops) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "at least one stop is required"})
+		return
+	}
+	if len(req.Stops) > maxBatchStops {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "too many stops in batch"})
+		return
+	}
+	for _, st := range req.Stops {
+		if st.Code == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "stop code is required"})
+			return
+		}
+	}
+
+	results := make([]BatchStopResult, len(req.Stops))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, st := range req.Stops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = h.fetchStop(r, st)
+		}()
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, BatchArrivalResponse{Results: results})
+}
+
+func (h *BatchArrivals) fetchStop(r *http.Request, st batchStopReq) BatchStopResult {
+	res := BatchStopResult{StopArrivalResponse: StopArrivalResponse{BusStopCode: st.Code}}
+
+	arrivals, err := h.lta.GetBusArrival(r.Context(), st.Code, "")
+	if err != nil {
+		slog.Warn("Batch arrivals: failed to fetch stop", "code", st.Code, "error", err)
+		_, res.Error = upstreamStatus(err, "Failed to fetch arrivals")
+		return res
+	}
+
+	var wanted map[string]bool
+	if len(st.Services) > 0 {
+		wanted = make(map[string]bool, len(st.Services))
+		for _, no := range st.Services {
+			wanted[no] = true
+		}
+	}
+
+	res.markStale(arrivals)
+
+	now := time.Now()
+	res.Services = []ServiceTiming{}
+	for _, svc := range arrivals.Services {
+		if wanted != nil && !wanted[svc.ServiceNumber] {
+			continue
+		}
+		res.Services = append(res.Services, newServiceTiming(svc, now))
+	}
+	sortServiceTimings(res.Services)
+	return res
+}
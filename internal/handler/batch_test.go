@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// batchServices is what every stop reports, except 99999 which fails.
+var batchServices = []lta.Service{
+	{ServiceNumber: "196", Operator: "SMRT"},
+	{ServiceNumber: "10", Operator: "SBST"},
+}
+
+var batchErrs = map[string]error{"99999": errors.New("upstream failure")}
+
+func TestBatchArrivals(t *testing.T) {
+	h := NewBatchArrivals(&arrivalsMockLTA{anyStop: batchServices, errs: batchErrs})
+
+	body := `{"stops":[{"code":"12345"},{"code":"99999"},{"code":"54321","services":["196"]}]}`
+	req := httptest.NewRequest("POST", "/api/v1/arrivals/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp BatchArrivalResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+
+	first := resp.Results[0]
+	if first.BusStopCode != "12345" || first.Error != "" {
+		t.Errorf("unexpected first result: %+v", first)
+	}
+	if len(first.Services) != 2 || first.Services[0].ServiceNumber != "10" {
+		t.Errorf("expected 2 sorted services, got %+v", first.Services)
+	}
+
+	if resp.Results[1].BusStopCode != "99999" || resp.Results[1].Error == "" {
+		t.Errorf("expected per-stop error for 99999, got %+v", resp.Results[1])
+	}
+
+	third := resp.Results[2]
+	if len(third.Services) != 1 || third.Services[0].ServiceNumber != "196" {
+		t.Errorf("expected only service 196, got %+v", third.Services)
+	}
+}
+
+func TestBatchArrivalsBadRequest(t *testing.T) {
+	h := NewBatchArrivals(&arrivalsMockLTA{anyStop: batchServices, errs: batchErrs})
+
+	tooMany := `{"stops":[` + strings.Repeat(`{"code":"1"},`, maxBatchStops) + `{"code":"1"}]}`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"no stops", `{"stops":[]}`},
+		{"missing code", `{"stops":[{"code":""}]}`},
+		{"too many stops", tooMany},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/arrivals/batch", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestBatchArrivalsBodyTooLarge(t *testing.T) {
+	h := NewBatchArrivals(&arrivalsMockLTA{anyStop: batchServices, errs: batchErrs})
+
+	body := `{"stops":[{"code":"1","services":["` + strings.Repeat("9", maxBatchBodyBytes) + `"]}]}`
+	req := httptest.NewRequest("POST", "/api/v1/arrivals/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+}
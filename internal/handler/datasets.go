@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// Datasets serves the admin endpoints for LTA dataset versions at
+// /api/v1/admin/datasets. They need the admin token as a bearer token and
+// are disabled when none is configured.
+type Datasets struct {
+	store      *store.Store
+	adminToken string
+}
+
+func NewDatasets(s *store.Store, adminToken string) *Datasets {
+	return &Datasets{store: s, adminToken: adminToken}
+}
+
+func (h *Datasets) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "admin endpoints are disabled"})
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(h.adminToken)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+		return false
+	}
+	return true
+}
+
+// List returns the live, previous and any in-progress datasets.
+func (h *Datasets) List(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	versions, err := h.store.Datasets()
+	if err != nil {
+		slog.Error("Error listing datasets", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list datasets"})
+		return
+	}
+	if versions == nil {
+		versions = []store.DatasetVersion{}
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// Rollback makes the previous dataset live and returns it.
+func (h *Datasets) Rollback(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	v, err := h.store.RollbackDataset()
+	if errors.Is(err, store.ErrNoPreviousDataset) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("Error rolling back dataset", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to roll back dataset"})
+		return
+	}
+	slog.Info("Rolled back to previous dataset", "version", v.Version)
+	writeJSON(w, http.StatusOK, v)
+}
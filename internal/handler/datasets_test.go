@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+func adminRequest(method, path, token string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestDatasetsAuth(t *testing.T) {
+	s := testStore(t)
+
+	rec := httptest.NewRecorder()
+	NewDatasets(s, "").List(rec, adminRequest("GET", "/api/v1/admin/datasets", "anything"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without an admin token configured, got %d", rec.Code)
+	}
+
+	h := NewDatasets(s, "secret")
+	for _, token := range []string{"", "wrong"} {
+		rec := httptest.NewRecorder()
+		h.Rollback(rec, adminRequest("POST", "/api/v1/admin/datasets/rollback", token))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+}
+
+func TestDatasetsRollback(t *testing.T) {
+	s := testStore(t)
+	h := NewDatasets(s, "secret")
+
+	rec := httptest.NewRecorder()
+	h.Rollback(rec, adminRequest("POST", "/api/v1/admin/datasets/rollback", "secret"))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 with no previous dataset, got %d", rec.Code)
+	}
+
+	build, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := build.Sync([]lta.BusStop{{BusStopCode: "12345"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := build.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.List(rec, adminRequest("GET", "/api/v1/admin/datasets", "secret"))
+	var versions []store.DatasetVersion
+	if err := json.NewDecoder(rec.Body).Decode(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != build.Version() || versions[0].State != store.DatasetLive {
+		t.Fatalf("unexpected datasets %+v", versions)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Rollback(rec, adminRequest("POST", "/api/v1/admin/datasets/rollback", "secret"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var live store.DatasetVersion
+	if err := json.NewDecoder(rec.Body).Decode(&live); err != nil {
+		t.Fatal(err)
+	}
+	if live.Version != versions[1].Version {
+		t.Errorf("expected version %d live, got %+v", versions[1].Version, live)
+	}
+	if stop, err := s.GetStop("12345"); err != nil || stop != nil {
+		t.Errorf("expected the rolled-back dataset without 12345, got %+v, %v", stop, err)
+	}
+}
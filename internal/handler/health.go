@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// HealthClient exposes the operational counters of the LTA client.
+type HealthClient interface {
+	CacheStats() lta.CacheStats
+	BreakerStatus() lta.BreakerStatus
+}
+
+// Health reports service status and upstream client internals for operators.
+type Health struct {
+	lta HealthClient
+}
+
+func NewHealth(client HealthClient) *Health {
+	return &Health{lta: client}
+}
+
+type healthResp struct {
+	Status         string            `json:"status"`
+	ArrivalCache   lta.CacheStats    `json:"arrivalCache"`
+	ArrivalBreaker lta.BreakerStatus `json:"arrivalBreaker"`
+}
+
+// ServeHTTP always answers 200 so the process isn't restarted for an
+// upstream outage; status is "degraded" while the arrival breaker isn't
+// closed.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := healthResp{
+		Status:         "ok",
+		ArrivalCache:   h.lta.CacheStats(),
+		ArrivalBreaker: h.lta.BreakerStatus(),
+	}
+	if resp.ArrivalBreaker.State != lta.BreakerClosed.String() {
+		resp.Status = "degraded"
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
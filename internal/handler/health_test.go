@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+type healthMockLTA struct{ breaker string }
+
+func (m *healthMockLTA) CacheStats() lta.CacheStats {
+	return lta.CacheStats{Hits: 7, Misses: 3, Evictions: 1, Size: 2, Capacity: 10}
+}
+
+func (m *healthMockLTA) BreakerStatus() lta.BreakerStatus {
+	if m.breaker == "" {
+		return lta.BreakerStatus{State: "closed"}
+	}
+	return lta.BreakerStatus{State: m.breaker, ConsecutiveFailures: 5}
+}
+
+func TestHealthHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	NewHealth(&healthMockLTA{}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp healthResp
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("expected status ok, got %q", resp.Status)
+	}
+	if resp.ArrivalCache.Hits != 7 || resp.ArrivalCache.Size != 2 {
+		t.Errorf("unexpected cache stats: %+v", resp.ArrivalCache)
+	}
+}
+
+func TestHealthHandlerDegradedWhenBreakerOpen(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	NewHealth(&healthMockLTA{breaker: "open"}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp healthResp
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if resp.Status != "degraded" {
+		t.Errorf("expected degraded, got %q", resp.Status)
+	}
+	if resp.ArrivalBreaker.State != "open" || resp.ArrivalBreaker.ConsecutiveFailures != 5 {
+		t.Errorf("unexpected breaker status: %+v", resp.ArrivalBreaker)
+	}
+}
@@ -4,11 +4,18 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/aattwwss/yabatasg/internal/store"
 )
 
+// maxNearbyRadius caps the radius parameter, in meters.
+const maxNearbyRadius = 5000.0
+
+// Nearby serves /api/v1/stops/nearby?lat=&lng=. Optional parameters:
+// radius (meters, default 3000), limit (default 15), and services=true to
+// list the services calling at each stop.
 type Nearby struct {
 	store *store.Store
 }
@@ -17,6 +24,13 @@ func NewNearby(s *store.Store) *Nearby {
 	return &Nearby{store: s}
 }
 
+// NearbyStop is a nearby stop with, when requested, the services calling
+// at it.
+type NearbyStop struct {
+	store.StopWithDistance
+	Services []string `json:"services,omitempty"`
+}
+
 func (h *Nearby) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -52,7 +66,18 @@ func (h *Nearby) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	stops, err := h.store.Nearby(lat, lng, limit)
+	radius := store.DefaultNearbyRadius
+	if s := q.Get("radius"); s != "" {
+		r, err := strconv.ParseFloat(s, 64)
+		if err != nil || r <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid radius"})
+			return
+		}
+		radius = min(r, maxNearbyRadius)
+	}
+
+	stops, err := h.store.NearbyWithin(lat, lng, radius, limit)
 	if err != nil {
 		slog.Error("Error querying nearby stops", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -60,11 +85,30 @@ func (h *Nearby) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if stops == nil {
-		stops = []store.StopWithDistance{}
+	var services map[string][]string
+	if q.Get("services") == "true" {
+		codes := make([]string, len(stops))
+		for i, st := range stops {
+			codes[i] = st.Code
+		}
+		services, err = h.store.ServicesAtStops(codes)
+		if err != nil {
+			slog.Error("Error querying services at nearby stops", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to query nearby stops"})
+			return
+		}
+	}
+
+	results := make([]NearbyStop, len(stops))
+	for i, st := range stops {
+		results[i] = NearbyStop{StopWithDistance: st, Services: services[st.Code]}
+		sort.Slice(results[i].Services, func(a, b int) bool {
+			return serviceLess(results[i].Services[a], results[i].Services[b])
+		})
 	}
 
-	if err := json.NewEncoder(w).Encode(stops); err != nil {
+	if err := json.NewEncoder(w).Encode(results); err != nil {
 		slog.Error("Error encoding response", "error", err)
 	}
 }
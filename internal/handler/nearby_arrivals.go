@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+const (
+	// defaultWalkRadius and maxWalkRadius bound the radius parameter of
+	// /api/v1/nearby/arrivals, in meters.
+	defaultWalkRadius = 400.0
+	maxWalkRadius     = 1000.0
+	// maxNearbyArrivalStops caps the upstream calls per request; the
+	// closest stops within the radius are queried.
+	maxNearbyArrivalStops = 12
+)
+
+// NearbyArrivals serves /api/v1/nearby/arrivals?lat=&lng=: every service
+// that can be caught within walking distance, each listed once at the
+// nearest stop where a bus is due.
+type NearbyArrivals struct {
+	store *store.Store
+	lta   LTAClient
+}
+
+func NewNearbyArrivals(s *store.Store, client LTAClient) *NearbyArrivals {
+	return &NearbyArrivals{store: s, lta: client}
+}
+
+type NearbyArrivalsResponse struct {
+	Services []NearbyServiceArrival `json:"services"`
+	// UnavailableStops lists nearby stops whose arrivals couldn't be
+	// fetched, so services only calling there are missing.
+	UnavailableStops []string `json:"unavailableStops,omitempty"`
+}
+
+// NearbyServiceArrival is a service's next buses at its nearest stop.
+// Distance is the straight-line distance to that stop in meters.
+type NearbyServiceArrival struct {
+	ServiceTiming
+	StopCode    string `json:"stopCode"`
+	RoadName    string `json:"roadName"`
+	Description string `json:"description"`
+	Distance    int    `json:"distance"`
+}
+
+func (h *NearbyArrivals) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	lng, errLng := strconv.ParseFloat(q.Get("lng"), 64)
+	if errLat != nil || errLng != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "valid lat and lng are required"})
+		return
+	}
+
+	radius := defaultWalkRadius
+	if s := q.Get("radius"); s != "" {
+		r, err := strconv.ParseFloat(s, 64)
+		if err != nil || r <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid radius"})
+			return
+		}
+		radius = min(r, maxWalkRadius)
+	}
+
+	stops, err := h.store.NearbyWithin(lat, lng, radius, maxNearbyArrivalStops)
+	if err != nil {
+		slog.Error("Error querying nearby stops", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to query nearby stops"})
+		return
+	}
+
+	resp := NearbyArrivalsResponse{Services: []NearbyServiceArrival{}}
+	if len(stops) == 0 {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	results := make([]*lta.BusArrival, len(stops))
+	errs := make([]error, len(stops))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, st := range stops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = h.lta.GetBusArrival(r.Context(), st.Code, "")
+			if errs[i] != nil {
+				slog.Warn("Nearby arrivals: failed to fetch stop", "code", st.Code, "error", errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !slices.ContainsFunc(errs, func(err error) bool { return err == nil }) {
+		writeUpstreamError(w, errs[0], "Failed to fetch arrivals")
+		return
+	}
+
+	// Stops are closest first, so the first stop with a bus due is the
+	// service's nearest.
+	now := time.Now()
+	seen := make(map[string]bool)
+	for i, st := range stops {
+		if errs[i] != nil {
+			resp.UnavailableStops = append(resp.UnavailableStops, st.Code)
+			continue
+		}
+		for _, svc := range results[i].Services {
+			if seen[svc.ServiceNumber] || svc.NextBus.EstimatedArrival.IsZero() {
+				continue
+			}
+			seen[svc.ServiceNumber] = true
+			resp.Services = append(resp.Services, NearbyServiceArrival{
+				ServiceTiming: newServiceTiming(svc, now),
+				StopCode:      st.Code,
+				RoadName:      st.RoadName,
+				Description:   st.Description,
+				Distance:      int(math.Round(st.Distance)),
+			})
+		}
+	}
+
+	sort.SliceStable(resp.Services, func(i, j int) bool {
+		a, b := resp.Services[i], resp.Services[j]
+		if *a.Next1 != *b.Next1 {
+			return *a.Next1 < *b.Next1
+		}
+		return serviceLess(a.ServiceNumber, b.ServiceNumber)
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func dueIn(no string, d time.Duration) lta.Service {
+	svc := lta.Service{ServiceNumber: no, Operator: "SBST"}
+	if d > 0 {
+		svc.NextBus.EstimatedArrival = lta.SafeTime{Time: time.Now().Add(d)}
+	}
+	return svc
+}
+
+var nearbyArrivalsDataset = testDataset{
+	stops: []lta.BusStop{
+		{BusStopCode: "NEAR", Description: "Near", Latitude: 1.3005, Longitude: 103.8000}, // ~55 m
+		{BusStopCode: "FAR", Description: "Far", Latitude: 1.3030, Longitude: 103.8000},   // ~330 m
+		{BusStopCode: "AWAY", Description: "Away", Latitude: 1.3150, Longitude: 103.8000}, // ~1.7 km
+	},
+}
+
+func TestNearbyArrivalsHandler(t *testing.T) {
+	client := &arrivalsMockLTA{services: map[string][]lta.Service{
+		// 196 is listed at NEAR but has no bus due, so FAR is its best stop.
+		"NEAR": {dueIn("10", 5*time.Minute+30*time.Second), dueIn("196", 0)},
+		"FAR":  {dueIn("10", 90*time.Second), dueIn("196", 8*time.Minute+30*time.Second), dueIn("30", 30*time.Second)},
+		"AWAY": {dueIn("99", time.Minute)},
+	}}
+	h := NewNearbyArrivals(seededStore(t, nearbyArrivalsDataset), client)
+
+	req := httptest.NewRequest("GET", "/api/v1/nearby/arrivals?lat=1.3&lng=103.8", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp NearbyArrivalsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	want := []struct {
+		service, stop string
+		next1         int
+	}{
+		{"30", "FAR", 0},
+		{"10", "NEAR", 5},
+		{"196", "FAR", 8},
+	}
+	if len(resp.Services) != len(want) {
+		t.Fatalf("expected %d services, got %+v", len(want), resp.Services)
+	}
+	for i, w := range want {
+		got := resp.Services[i]
+		if got.ServiceNumber != w.service || got.StopCode != w.stop || *got.Next1 != w.next1 {
+			t.Errorf("services[%d] = %s at %s in %d min, want %s at %s in %d min",
+				i, got.ServiceNumber, got.StopCode, *got.Next1, w.service, w.stop, w.next1)
+		}
+	}
+	if resp.Services[1].Distance < 50 || resp.Services[1].Distance > 60 {
+		t.Errorf("expected NEAR to be ~55 m away, got %d", resp.Services[1].Distance)
+	}
+}
+
+func TestNearbyArrivalsPartialFailure(t *testing.T) {
+	client := &arrivalsMockLTA{services: map[string][]lta.Service{
+		"NEAR": {dueIn("10", 5*time.Minute)},
+	}}
+	h := NewNearbyArrivals(seededStore(t, nearbyArrivalsDataset), client)
+
+	req := httptest.NewRequest("GET", "/api/v1/nearby/arrivals?lat=1.3&lng=103.8", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp NearbyArrivalsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Services) != 1 || len(resp.UnavailableStops) != 1 || resp.UnavailableStops[0] != "FAR" {
+		t.Errorf("expected 10 with FAR unavailable, got %+v", resp)
+	}
+
+	// Every stop failing is an upstream error.
+	h = NewNearbyArrivals(seededStore(t, nearbyArrivalsDataset), &arrivalsMockLTA{})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/nearby/arrivals?lat=1.3&lng=103.8", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestNearbyArrivalsBadParams(t *testing.T) {
+	h := NewNearbyArrivals(testStore(t), &arrivalsMockLTA{})
+	for _, q := range []string{"", "?lat=1.3", "?lat=x&lng=103.8", "?lat=1.3&lng=103.8&radius=0"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/nearby/arrivals"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
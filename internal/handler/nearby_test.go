@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/aattwwss/yabatasg/internal/lta"
 	"github.com/aattwwss/yabatasg/internal/store"
 )
 
@@ -87,3 +88,58 @@ func TestNearbyHandlerInvalidParams(t *testing.T) {
 		t.Errorf("expected 400, got %d", rec.Code)
 	}
 }
+
+func TestNearbyHandlerRadiusAndServices(t *testing.T) {
+	s := seededStore(t, testDataset{
+		stops: []lta.BusStop{
+			{BusStopCode: "A", Latitude: 1.3000, Longitude: 103.8000},
+			{BusStopCode: "B", Latitude: 1.3030, Longitude: 103.8000}, // ~330 m
+			{BusStopCode: "C", Latitude: 1.3100, Longitude: 103.8000}, // ~1.1 km
+		},
+		routes: []lta.BusRoute{
+			{ServiceNo: "196", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+			{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+			{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "B"},
+		},
+	})
+	h := NewNearby(s)
+
+	req := httptest.NewRequest("GET", "/api/v1/stops/nearby?lat=1.3&lng=103.8&radius=500&services=true", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var stops []NearbyStop
+	if err := json.NewDecoder(rec.Body).Decode(&stops); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stops) != 2 || stops[0].Code != "A" || stops[1].Code != "B" {
+		t.Fatalf("expected A and B within 500 m, got %+v", stops)
+	}
+	if svcs := stops[0].Services; len(svcs) != 2 || svcs[0] != "10" || svcs[1] != "196" {
+		t.Errorf("expected [10 196] at A, got %v", svcs)
+	}
+
+	// Services are only listed when asked for.
+	req = httptest.NewRequest("GET", "/api/v1/stops/nearby?lat=1.3&lng=103.8", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	stops = nil
+	if err := json.NewDecoder(rec.Body).Decode(&stops); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stops) != 3 {
+		t.Fatalf("expected all 3 stops within the default radius, got %+v", stops)
+	}
+	if stops[0].Services != nil {
+		t.Errorf("expected no services by default, got %v", stops[0].Services)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/stops/nearby?lat=1.3&lng=103.8&radius=-1", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative radius, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aattwwss/yabatasg/internal/planner"
+)
+
+// Plan serves journey plans at /api/v1/plan. Each endpoint is either a stop
+// code (from, to) or a coordinate (fromLat/fromLng, toLat/toLng); an
+// optional maxTransfers limits the search (default and maximum 2).
+type Plan struct {
+	planner *planner.Planner
+}
+
+func NewPlan(p *planner.Planner) *Plan {
+	return &Plan{planner: p}
+}
+
+type PlanResponse struct {
+	Itineraries []planner.Itinerary `json:"itineraries"`
+}
+
+func (h *Plan) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, ok := parsePlace(q, "from")
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from or fromLat/fromLng is required"})
+		return
+	}
+	to, ok := parsePlace(q, "to")
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "to or toLat/toLng is required"})
+		return
+	}
+	if from.StopCode != "" && from.StopCode == to.StopCode {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from and to must be different stops"})
+		return
+	}
+
+	maxTransfers := planner.MaxTransfers
+	if s := q.Get("maxTransfers"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > planner.MaxTransfers {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "maxTransfers must be 0, 1 or 2"})
+			return
+		}
+		maxTransfers = n
+	}
+
+	its, err := h.planner.Plan(r.Context(), from, to, maxTransfers)
+	if err != nil {
+		if errors.Is(err, planner.ErrUnknownStop) || errors.Is(err, planner.ErrNoStopsNearby) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		slog.Error("Failed to plan journey", "from", from, "to", to, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to plan journey"})
+		return
+	}
+	if its == nil {
+		its = []planner.Itinerary{}
+	}
+	writeJSON(w, http.StatusOK, PlanResponse{Itineraries: its})
+}
+
+// parsePlace reads an endpoint from either a stop code parameter (prefix)
+// or a prefixLat/prefixLng pair.
+func parsePlace(q url.Values, prefix string) (planner.Place, bool) {
+	if code := q.Get(prefix); code != "" {
+		return planner.Place{StopCode: code}, true
+	}
+	lat, err := strconv.ParseFloat(q.Get(prefix+"Lat"), 64)
+	if err != nil {
+		return planner.Place{}, false
+	}
+	lng, err := strconv.ParseFloat(q.Get(prefix+"Lng"), 64)
+	if err != nil {
+		return planner.Place{}, false
+	}
+	return planner.Place{Lat: lat, Lng: lng}, true
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/planner"
+)
+
+func TestPlanHandler(t *testing.T) {
+	h := NewPlan(planner.New(seededStore(t, tripsDataset), &mockLTA{}))
+
+	req := httptest.NewRequest("GET", "/api/v1/plan?from=12345&to=67890", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp PlanResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Itineraries) == 0 {
+		t.Fatal("expected at least one itinerary")
+	}
+	for _, it := range resp.Itineraries {
+		if it.Transfers != 0 || len(it.Legs) != 1 || it.Legs[0].Mode != "bus" {
+			t.Errorf("expected direct bus itineraries, got %+v", it)
+		}
+	}
+}
+
+func TestPlanHandlerErrors(t *testing.T) {
+	h := NewPlan(planner.New(seededStore(t, tripsDataset), &mockLTA{}))
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"?to=67890", http.StatusBadRequest},
+		{"?from=12345", http.StatusBadRequest},
+		{"?from=12345&to=12345", http.StatusBadRequest},
+		{"?from=12345&to=67890&maxTransfers=3", http.StatusBadRequest},
+		{"?fromLat=abc&fromLng=103.8&to=67890", http.StatusBadRequest},
+		{"?from=99999&to=67890", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/plan"+tt.query, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.query, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+}
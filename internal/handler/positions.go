@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// maxPositionSamples caps how many stops per direction are queried for a
+// service's live positions. Each stop reports up to three approaching buses,
+// so evenly spaced samples cover most of the route without one upstream
+// call per stop.
+const maxPositionSamples = 10
+
+// ServicePositions serves approximate live bus positions for a service,
+// sampled from arrivals at stops along its route.
+type ServicePositions struct {
+	store *store.Store
+	lta   LTAClient
+}
+
+func NewServicePositions(s *store.Store, client LTAClient) *ServicePositions {
+	return &ServicePositions{store: s, lta: client}
+}
+
+type ServicePositionsResponse struct {
+	ServiceNo  string               `json:"serviceNo"`
+	Directions []DirectionPositions `json:"directions"`
+	AsOf       time.Time            `json:"asOf"`
+}
+
+type DirectionPositions struct {
+	Direction int           `json:"direction"`
+	Buses     []BusPosition `json:"buses"`
+}
+
+// BusPosition is one monitored bus. NextStopCode is the sampled stop it is
+// due at soonest, and ETASeconds its estimated time to get there.
+type BusPosition struct {
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	NextStopCode string  `json:"nextStopCode"`
+	ETASeconds   int     `json:"etaSeconds"`
+	Load         string  `json:"load"`
+	Type         string  `json:"type"`
+}
+
+func (h *ServicePositions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serviceNo := r.PathValue("no")
+	if serviceNo == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "service number is required"})
+		return
+	}
+
+	stops, err := h.store.GetStopsByService(serviceNo)
+	if err != nil {
+		slog.Error("Error getting stops by service", "serviceNo", serviceNo, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get stops"})
+		return
+	}
+	if len(stops) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "service not found"})
+		return
+	}
+
+	byDir := make(map[int][]store.ServiceStop)
+	var dirs []int
+	for _, st := range stops {
+		if _, ok := byDir[st.Direction]; !ok {
+			dirs = append(dirs, st.Direction)
+		}
+		byDir[st.Direction] = append(byDir[st.Direction], st)
+	}
+	sort.Ints(dirs)
+
+	type sample struct {
+		dir  int
+		stop string
+	}
+	var samples []sample
+	for _, d := range dirs {
+		for _, st := range sampleStops(byDir[d], maxPositionSamples) {
+			samples = append(samples, sample{dir: d, stop: st.StopCode})
+		}
+	}
+
+	results := make([]*lta.BusArrival, len(samples))
+	errs := make([]error, len(samples))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, s := range samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = h.lta.GetBusArrival(r.Context(), s.stop, serviceNo)
+			if errs[i] != nil {
+				slog.Warn("Positions: failed to fetch stop", "serviceNo", serviceNo, "code", s.stop, "error", errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Partial results are still useful; only fail if every sample failed.
+	if !slices.ContainsFunc(errs, func(err error) bool { return err == nil }) {
+		writeUpstreamError(w, errs[0], "Failed to fetch arrivals")
+		return
+	}
+
+	now := time.Now()
+	resp := ServicePositionsResponse{ServiceNo: serviceNo, AsOf: now}
+	for _, d := range dirs {
+		seen := make(map[string][]int)
+		dp := DirectionPositions{Direction: d, Buses: []BusPosition{}}
+		for i, s := range samples {
+			if s.dir != d || results[i] == nil {
+				continue
+			}
+			for _, svc := range results[i].Services {
+				if svc.ServiceNumber != serviceNo {
+					continue
+				}
+				matched := make(map[int]bool)
+				for _, nb := range []lta.NextBus{svc.NextBus, svc.NextBus2, svc.NextBus3} {
+					addBusPosition(&dp, seen, matched, nb, s.stop, now)
+				}
+			}
+		}
+		resp.Directions = append(resp.Directions, dp)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// addBusPosition records a monitored bus, merging reports of the same bus
+// from different stops. A bus seen from several stops keeps the report with
+// the soonest ETA, i.e. the stop it is approaching next.
+//
+// seen maps a rounded position to the buses reported there. matched holds
+// the buses the current stop's report has already matched: its next-bus
+// slots are always different buses, so two of them at one spot stay apart.
+func addBusPosition(dp *DirectionPositions, seen map[string][]int, matched map[int]bool, nb lta.NextBus, stopCode string, now time.Time) {
+	if nb.Monitored != 1 || nb.EstimatedArrival.IsZero() {
+		return
+	}
+	lat, lng, ok := parseBusPosition(nb)
+	if !ok {
+		return
+	}
+	eta := int(nb.EstimatedArrival.Sub(now).Seconds())
+	if eta < 0 {
+		eta = 0
+	}
+	pos := BusPosition{
+		Latitude:     lat,
+		Longitude:    lng,
+		NextStopCode: stopCode,
+		ETASeconds:   eta,
+		Load:         nb.Load,
+		Type:         nb.Type,
+	}
+
+	// Positions are reported to ~1 m; rounding to 4 decimals (~11 m)
+	// absorbs small differences between reports of the same bus.
+	key := fmt.Sprintf("%.4f,%.4f", lat, lng)
+	for _, i := range seen[key] {
+		if matched[i] {
+			continue
+		}
+		matched[i] = true
+		if eta < dp.Buses[i].ETASeconds {
+			dp.Buses[i] = pos
+		}
+		return
+	}
+	matched[len(dp.Buses)] = true
+	seen[key] = append(seen[key], len(dp.Buses))
+	dp.Buses = append(dp.Buses, pos)
+}
+
+// sampleStops picks up to n stops spread evenly along a direction, always
+// including the first and last stops so buses leaving the origin and near
+// the terminus are seen.
+func sampleStops(stops []store.ServiceStop, n int) []store.ServiceStop {
+	if len(stops) <= n {
+		return stops
+	}
+	if n < 2 {
+		return stops[len(stops)-n:]
+	}
+	out := make([]store.ServiceStop, 0, n)
+	for i := range n {
+		out = append(out, stops[i*(len(stops)-1)/(n-1)])
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// busService reports service no with buses as its next arrivals.
+func busService(no string, buses ...lta.NextBus) lta.Service {
+	buses = append(buses, lta.NextBus{}, lta.NextBus{}, lta.NextBus{})
+	return lta.Service{ServiceNumber: no, NextBus: buses[0], NextBus2: buses[1], NextBus3: buses[2]}
+}
+
+func monitoredBus(now time.Time, eta time.Duration, lat, lng string) lta.NextBus {
+	return lta.NextBus{
+		EstimatedArrival: lta.SafeTime{Time: now.Add(eta)},
+		Monitored:        1,
+		Latitude:         lat,
+		Longitude:        lng,
+		Load:             "SEA",
+		Type:             "DD",
+	}
+}
+
+var positionsDataset = testDataset{
+	routes: []lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A1"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "A2"},
+		{ServiceNo: "10", Direction: 2, StopSequence: 1, BusStopCode: "B1"},
+	},
+}
+
+func TestServicePositions(t *testing.T) {
+	now := time.Now()
+	client := &arrivalsMockLTA{
+		anyStop: []lta.Service{},
+		services: map[string][]lta.Service{
+			// The same bus is seen approaching A1 and, further on, A2.
+			"A1": {busService("10", monitoredBus(now, 2*time.Minute, "1.30001", "103.80001"))},
+			"A2": {busService("10",
+				monitoredBus(now, 5*time.Minute, "1.30001", "103.80001"),
+				lta.NextBus{EstimatedArrival: lta.SafeTime{Time: now.Add(12 * time.Minute)}, Latitude: "0.0", Longitude: "0.0"},
+			)},
+			"B1": {busService("10", monitoredBus(now, time.Minute, "1.35", "103.85"))},
+		},
+	}
+	h := NewServicePositions(seededStore(t, positionsDataset), client)
+
+	req := httptest.NewRequest("GET", "/api/v1/services/10/positions", nil)
+	req.SetPathValue("no", "10")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ServicePositionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Directions) != 2 {
+		t.Fatalf("expected 2 directions, got %d", len(resp.Directions))
+	}
+
+	d1 := resp.Directions[0]
+	if d1.Direction != 1 || len(d1.Buses) != 1 {
+		t.Fatalf("expected one de-duplicated bus in direction 1, got %+v", d1)
+	}
+	if d1.Buses[0].NextStopCode != "A1" {
+		t.Errorf("expected bus to be approaching A1, got %s", d1.Buses[0].NextStopCode)
+	}
+
+	d2 := resp.Directions[1]
+	if len(d2.Buses) != 1 || d2.Buses[0].Latitude != 1.35 {
+		t.Errorf("unexpected direction 2 buses: %+v", d2)
+	}
+}
+
+func TestServicePositionsSameSpot(t *testing.T) {
+	now := time.Now()
+	client := &arrivalsMockLTA{
+		anyStop: []lta.Service{},
+		services: map[string][]lta.Service{
+			// Two buses bunched at one spot, both reported by A1.
+			"A1": {busService("10",
+				monitoredBus(now, 2*time.Minute, "1.30001", "103.80001"),
+				monitoredBus(now, 3*time.Minute, "1.30002", "103.80002"),
+			)},
+			// A2 sees the same two buses further on.
+			"A2": {busService("10",
+				monitoredBus(now, 5*time.Minute, "1.30001", "103.80001"),
+				monitoredBus(now, 6*time.Minute, "1.30002", "103.80002"),
+			)},
+		},
+	}
+	h := NewServicePositions(seededStore(t, positionsDataset), client)
+
+	req := httptest.NewRequest("GET", "/api/v1/services/10/positions", nil)
+	req.SetPathValue("no", "10")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp ServicePositionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	buses := resp.Directions[0].Buses
+	if len(buses) != 2 {
+		t.Fatalf("expected both bunched buses, got %+v", buses)
+	}
+	for _, b := range buses {
+		if b.NextStopCode != "A1" {
+			t.Errorf("expected each bus approaching A1, got %+v", b)
+		}
+	}
+}
+
+func TestServicePositionsFirstStop(t *testing.T) {
+	var routes []lta.BusRoute
+	for i := range 3 * maxPositionSamples {
+		routes = append(routes, lta.BusRoute{ServiceNo: "20", Direction: 1, StopSequence: i + 1, BusStopCode: fmt.Sprintf("S%02d", i)})
+	}
+	s := seededStore(t, testDataset{routes: routes})
+
+	now := time.Now()
+	client := &arrivalsMockLTA{
+		anyStop: []lta.Service{},
+		services: map[string][]lta.Service{
+			// A bus about to leave the first stop is only reported there.
+			"S00": {busService("20", monitoredBus(now, time.Minute, "1.3", "103.8"))},
+		},
+	}
+	h := NewServicePositions(s, client)
+
+	req := httptest.NewRequest("GET", "/api/v1/services/20/positions", nil)
+	req.SetPathValue("no", "20")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp ServicePositionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if buses := resp.Directions[0].Buses; len(buses) != 1 || buses[0].NextStopCode != "S00" {
+		t.Errorf("expected the bus at the first stop, got %+v", buses)
+	}
+}
+
+func TestServicePositionsErrors(t *testing.T) {
+	s := seededStore(t, positionsDataset)
+
+	t.Run("unknown service", func(t *testing.T) {
+		h := NewServicePositions(s, &arrivalsMockLTA{anyStop: []lta.Service{}})
+		req := httptest.NewRequest("GET", "/api/v1/services/999/positions", nil)
+		req.SetPathValue("no", "999")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("all samples fail", func(t *testing.T) {
+		h := NewServicePositions(s, &arrivalsMockLTA{err: lta.ErrCircuitOpen})
+		req := httptest.NewRequest("GET", "/api/v1/services/10/positions", nil)
+		req.SetPathValue("no", "10")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, got %d", rec.Code)
+		}
+	})
+}
+
+func TestSampleStops(t *testing.T) {
+	stops := make([]store.ServiceStop, 25)
+	for i := range stops {
+		stops[i].Sequence = i + 1
+	}
+	got := sampleStops(stops, 5)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 samples, got %d", len(got))
+	}
+	if got[0].Sequence != 1 {
+		t.Errorf("expected first stop to be sampled, got sequence %d", got[0].Sequence)
+	}
+	if got[len(got)-1].Sequence != 25 {
+		t.Errorf("expected last stop to be sampled, got sequence %d", got[len(got)-1].Sequence)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// ServiceSchedule is today's first and last bus of a service at a stop.
+// Times are "15:04" Singapore time. When the service has stopped for the
+// night (or not started yet), NoMoreBuses is set and NextFirstBus says when
+// the next bus leaves.
+type ServiceSchedule struct {
+	ServiceNo    string `json:"serviceNo"`
+	FirstBus     string `json:"firstBus,omitempty"`
+	LastBus      string `json:"lastBus,omitempty"`
+	NoMoreBuses  bool   `json:"noMoreBuses,omitempty"`
+	NextFirstBus string `json:"nextFirstBus,omitempty"`
+}
+
+// busTimesFor returns the first and last bus for the day type of wd.
+// Public holidays follow the Sunday timetable, but we have no holiday
+// calendar so they are treated as ordinary days.
+func busTimesFor(bt store.BusTimes, wd time.Weekday) (first, last string) {
+	switch wd {
+	case time.Saturday:
+		return bt.SATFirstBus, bt.SATLastBus
+	case time.Sunday:
+		return bt.SUNFirstBus, bt.SUNLastBus
+	default:
+		return bt.WDFirstBus, bt.WDLastBus
+	}
+}
+
+// parseBusTime parses a DataMall "HHmm" time into minutes after midnight.
+func parseBusTime(s string) (int, bool) {
+	if len(s) != 4 {
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	h, m := atoi(s[:2]), atoi(s[2:])
+	if h > 23 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// formatBusTime renders a DataMall "HHmm" time as "15:04", or "" if the
+// service doesn't run.
+func formatBusTime(s string) string {
+	if _, ok := parseBusTime(s); !ok {
+		return ""
+	}
+	return s[:2] + ":" + s[2:]
+}
+
+// newServiceSchedule works out today's first/last bus for a service and
+// whether it is still running at now. A last bus earlier than the first
+// (e.g. 00:30) runs past midnight and belongs to the previous day.
+func newServiceSchedule(serviceNo string, bt store.BusTimes, now time.Time) ServiceSchedule {
+	now = now.In(singaporeTime)
+	today := now.Weekday()
+	mins := now.Hour()*60 + now.Minute()
+
+	first, last := busTimesFor(bt, today)
+	sched := ServiceSchedule{
+		ServiceNo: serviceNo,
+		FirstBus:  formatBusTime(first),
+		LastBus:   formatBusTime(last),
+	}
+	if !hasBusTimes(bt) || runningAt(bt, today, mins) {
+		return sched
+	}
+
+	sched.NoMoreBuses = true
+	if f, ok := parseBusTime(first); ok && mins < f {
+		sched.NextFirstBus = sched.FirstBus
+		return sched
+	}
+	for i := 1; i <= 7; i++ {
+		f, _ := busTimesFor(bt, (today+time.Weekday(i))%7)
+		if t := formatBusTime(f); t != "" {
+			sched.NextFirstBus = t
+			break
+		}
+	}
+	return sched
+}
+
+func runningAt(bt store.BusTimes, today time.Weekday, mins int) bool {
+	if f, l, ok := busWindow(busTimesFor(bt, (today+6)%7)); ok && l < f && mins <= l {
+		return true
+	}
+	f, l, ok := busWindow(busTimesFor(bt, today))
+	if !ok {
+		return false
+	}
+	if l < f {
+		return mins >= f
+	}
+	return mins >= f && mins <= l
+}
+
+func busWindow(first, last string) (int, int, bool) {
+	f, ok := parseBusTime(first)
+	if !ok {
+		return 0, 0, false
+	}
+	l, ok := parseBusTime(last)
+	if !ok {
+		return 0, 0, false
+	}
+	return f, l, true
+}
+
+// hasBusTimes reports whether any day has a usable timetable. Without one
+// we can't tell whether a service has ended, so we say nothing.
+func hasBusTimes(bt store.BusTimes) bool {
+	for _, wd := range []time.Weekday{time.Monday, time.Saturday, time.Sunday} {
+		if _, _, ok := busWindow(busTimesFor(bt, wd)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// buildStopSchedules converts stored times into schedules, ordered by
+// service number.
+func buildStopSchedules(times []store.StopServiceTimes, now time.Time) []ServiceSchedule {
+	schedules := make([]ServiceSchedule, 0, len(times))
+	for _, t := range times {
+		schedules = append(schedules, newServiceSchedule(t.ServiceNo, t.BusTimes, now))
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return serviceLess(schedules[i].ServiceNo, schedules[j].ServiceNo)
+	})
+	return schedules
+}
+
+// nextFirstBus returns the earliest upcoming first bus when every service at
+// a stop has finished for the night, or "" if anything is still running.
+func nextFirstBus(schedules []ServiceSchedule) string {
+	var next string
+	for _, s := range schedules {
+		if !s.NoMoreBuses {
+			return ""
+		}
+		if s.NextFirstBus != "" && (next == "" || s.NextFirstBus < next) {
+			next = s.NextFirstBus
+		}
+	}
+	return next
+}
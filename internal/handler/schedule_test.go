@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+func sgt(day, hour, min int) time.Time {
+	// 2026-10-12 is a Monday.
+	return time.Date(2026, 10, 12+day, hour, min, 0, 0, singaporeTime)
+}
+
+func TestNewServiceSchedule(t *testing.T) {
+	bt := store.BusTimes{
+		WDFirstBus: "0542", WDLastBus: "0030",
+		SATFirstBus: "0600", SATLastBus: "2330",
+		SUNFirstBus: "-", SUNLastBus: "-",
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		noMore    bool
+		nextFirst string
+		first     string
+		last      string
+	}{
+		{"weekday daytime", sgt(0, 12, 0), false, "", "05:42", "00:30"},
+		{"weekday after midnight on previous day's service", sgt(1, 0, 15), false, "", "05:42", "00:30"},
+		{"weekday after last bus", sgt(1, 1, 0), true, "05:42", "05:42", "00:30"},
+		{"saturday after last bus, sunday not running", sgt(5, 23, 45), true, "05:42", "06:00", "23:30"},
+		{"sunday not running", sgt(6, 10, 0), true, "05:42", "", ""},
+		{"saturday early morning after friday's late bus", sgt(5, 0, 20), false, "", "06:00", "23:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newServiceSchedule("10", bt, tt.now)
+			if got.NoMoreBuses != tt.noMore || got.NextFirstBus != tt.nextFirst {
+				t.Errorf("got noMore=%v next=%q, want noMore=%v next=%q", got.NoMoreBuses, got.NextFirstBus, tt.noMore, tt.nextFirst)
+			}
+			if got.FirstBus != tt.first || got.LastBus != tt.last {
+				t.Errorf("got %q–%q, want %q–%q", got.FirstBus, got.LastBus, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestNewServiceScheduleWithoutTimes(t *testing.T) {
+	got := newServiceSchedule("10", store.BusTimes{}, sgt(0, 3, 0))
+	if got.NoMoreBuses {
+		t.Error("expected no claim about service hours without a timetable")
+	}
+}
+
+func TestNextFirstBus(t *testing.T) {
+	ended := []ServiceSchedule{
+		{ServiceNo: "10", NoMoreBuses: true, NextFirstBus: "05:42"},
+		{ServiceNo: "14", NoMoreBuses: true, NextFirstBus: "05:30"},
+	}
+	if got := nextFirstBus(ended); got != "05:30" {
+		t.Errorf("expected 05:30, got %q", got)
+	}
+
+	running := append(ended, ServiceSchedule{ServiceNo: "16"})
+	if got := nextFirstBus(running); got != "" {
+		t.Errorf("expected empty while a service runs, got %q", got)
+	}
+}
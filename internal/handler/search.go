@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aattwwss/yabatasg/internal/places"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// maxSearchResults caps the combined results returned by /api/v1/search.
+const maxSearchResults = 20
+
+var (
+	stopCodePattern  = regexp.MustCompile(`^\d{5}$`)
+	serviceNoPattern = regexp.MustCompile(`^[A-Za-z]{0,3}\d{1,3}[A-Za-z]?$`)
+)
+
+// Search is the omnibox behind /api/v1/search?q=. It accepts a stop code, a
+// service number, stop descriptions and road names, or the name of an MRT
+// station or landmark, and returns one ranked list of typed results.
+type Search struct {
+	store  *store.Store
+	places *places.Gazetteer
+}
+
+func NewSearch(s *store.Store, g *places.Gazetteer) *Search {
+	return &Search{store: s, places: g}
+}
+
+// SearchResult is one omnibox hit. Type is "stop", "service" or "place";
+// ID is the stop code, service number or place name respectively. Services
+// have no location.
+type SearchResult struct {
+	Type      string   `json:"type"`
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Subtitle  string   `json:"subtitle,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+
+	score int
+}
+
+// Ranking scores. A query that is exactly a stop code, service number or
+// place name puts that result first; partial matches follow, with stop
+// text matches last since they are the broadest.
+const (
+	scoreStopCode     = 100
+	scoreServiceExact = 90
+	scorePlaceExact   = 80
+	scorePlace        = 60
+	scoreService      = 55
+	scoreStopText     = 50
+)
+
+func (h *Search) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSON(w, http.StatusOK, []SearchResult{})
+		return
+	}
+
+	var results []SearchResult
+	seenStop := make(map[string]bool)
+
+	if stopCodePattern.MatchString(q) {
+		stop, err := h.store.GetStop(q)
+		if err != nil {
+			slog.Error("Search: failed to get stop", "code", q, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search"})
+			return
+		}
+		if stop != nil && stop.RetiredAt == nil {
+			results = append(results, stopResult(*stop, scoreStopCode))
+			seenStop[stop.Code] = true
+		}
+	}
+
+	if serviceNoPattern.MatchString(q) {
+		services, err := h.store.SearchServices(strings.ToUpper(q))
+		if err != nil {
+			slog.Error("Search: failed to search services", "q", q, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search"})
+			return
+		}
+		for _, svc := range services {
+			score := scoreService
+			if strings.EqualFold(svc.ServiceNo, q) {
+				score = scoreServiceExact
+			}
+			results = append(results, SearchResult{
+				Type:     "service",
+				ID:       svc.ServiceNo,
+				Title:    svc.ServiceNo,
+				Subtitle: svc.Operator,
+				score:    score,
+			})
+		}
+	}
+
+	for _, m := range h.places.Search(q, 5) {
+		score := scorePlace
+		if m.Exact {
+			score = scorePlaceExact
+		}
+		results = append(results, SearchResult{
+			Type:      "place",
+			ID:        m.Name,
+			Title:     m.Name,
+			Subtitle:  placeSubtitle(m.Place),
+			Latitude:  new(m.Latitude),
+			Longitude: new(m.Longitude),
+			score:     score,
+		})
+	}
+
+	stops, err := h.store.SearchStops(q, maxSearchResults)
+	if err != nil {
+		slog.Error("Search: failed to search stops", "q", q, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search"})
+		return
+	}
+	for _, st := range stops {
+		if !seenStop[st.Code] {
+			results = append(results, stopResult(st, scoreStopText))
+		}
+	}
+
+	// Stable, so results of equal score keep the order their source ranked
+	// them in.
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func stopResult(st store.Stop, score int) SearchResult {
+	return SearchResult{
+		Type:      "stop",
+		ID:        st.Code,
+		Title:     st.Description,
+		Subtitle:  st.Code + " · " + st.RoadName,
+		Latitude:  new(st.Latitude),
+		Longitude: new(st.Longitude),
+		score:     score,
+	}
+}
+
+func placeSubtitle(p places.Place) string {
+	if p.Kind != "mrt" {
+		return "Landmark"
+	}
+	if len(p.Aliases) == 0 {
+		return "MRT station"
+	}
+	return "MRT station · " + strings.Join(p.Aliases, " / ")
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/places"
+)
+
+func TestSearchHandler(t *testing.T) {
+	s := seededStore(t, testDataset{
+		stops: []lta.BusStop{
+			{BusStopCode: "53009", RoadName: "Bishan Rd", Description: "Bishan Int"},
+			{BusStopCode: "10009", RoadName: "Bt Merah Ctrl", Description: "Bt Merah Int"},
+		},
+	})
+	for _, svc := range []struct{ no, op string }{{"10", "SBST"}, {"100", "SBST"}, {"NR1", "SBST"}} {
+		if err := s.UpsertServiceOperator(svc.no, svc.op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := places.New([]places.Place{
+		{Name: "Bishan", Kind: "mrt", Aliases: []string{"NS17"}, Latitude: 1.351, Longitude: 103.8485},
+	})
+	h := NewSearch(s, g)
+
+	tests := []struct {
+		query string
+		want  []string // "type:id" in order
+	}{
+		{"53009", []string{"stop:53009"}},
+		{"10", []string{"service:10", "service:100", "stop:10009"}},
+		{"nr1", []string{"service:NR1"}},
+		{"bishan", []string{"place:Bishan", "stop:53009"}},
+		{"ns17", []string{"place:Bishan"}},
+		{"merah", []string{"stop:10009"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/search?q="+url.QueryEscape(tt.query), nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("q=%q: expected 200, got %d: %s", tt.query, rec.Code, rec.Body.String())
+		}
+		var results []SearchResult
+		if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+			t.Fatalf("q=%q: failed to decode: %v", tt.query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Type+":"+r.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("q=%q: got %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("q=%q: got %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -64,3 +64,40 @@ func (h *Service) Stops(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(stops)
 }
+
+// ServiceInfo describes a service as published in the BusServices dataset.
+type ServiceInfo struct {
+	ServiceNo  string                   `json:"serviceNo"`
+	Operator   string                   `json:"operator"`
+	Directions []store.ServiceDirection `json:"directions"`
+}
+
+func (h *Service) Info(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceNo := r.PathValue("no")
+	if serviceNo == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "service number is required"})
+		return
+	}
+
+	dirs, err := h.store.GetServiceDirections(serviceNo)
+	if err != nil {
+		slog.Error("Error getting service directions", "serviceNo", serviceNo, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get service"})
+		return
+	}
+	if len(dirs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "service not found"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(ServiceInfo{
+		ServiceNo:  serviceNo,
+		Operator:   dirs[0].Operator,
+		Directions: dirs,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/aattwwss/yabatasg/internal/store"
 )
@@ -38,6 +39,7 @@ func (h *ServicePage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now()
 	routeStops := make([]ServiceRouteStop, len(stops))
 	directionLabels := make(map[int]string)
 	var firstDirection int
@@ -50,7 +52,10 @@ func (h *ServicePage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Sequence:    s.Sequence,
 			Latitude:    s.Latitude,
 			Longitude:   s.Longitude,
+			BusTimes:    s.BusTimes,
 		}
+		sched := newServiceSchedule(serviceNo, s.BusTimes, now)
+		routeStops[i].FirstBus, routeStops[i].LastBus = sched.FirstBus, sched.LastBus
 		if firstDirection == 0 {
 			firstDirection = s.Direction
 		}
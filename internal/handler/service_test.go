@@ -22,12 +22,7 @@ func TestServiceSearchHandler(t *testing.T) {
 		{ServiceNo: "51", Direction: 1, StopSequence: 1, BusStopCode: "S1", Distance: 0},
 		{ServiceNo: "188", Direction: 1, StopSequence: 1, BusStopCode: "S3", Distance: 0},
 	}
-	if err := s.SyncRoutes(routes); err != nil {
-		t.Fatalf("SyncRoutes failed: %v", err)
-	}
-	if err := s.SeedServiceOperators(); err != nil {
-		t.Fatalf("SeedServiceOperators failed: %v", err)
-	}
+	seedStore(t, s, testDataset{routes: routes})
 
 	h := NewService(s)
 
@@ -93,9 +88,7 @@ func TestServiceStopsHandler(t *testing.T) {
 		{ServiceNo: "5", Direction: 1, StopSequence: 1, BusStopCode: "S1", Distance: 0},
 		{ServiceNo: "5", Direction: 1, StopSequence: 2, BusStopCode: "S2", Distance: 1.2},
 	}
-	if err := s.SyncRoutes(routes); err != nil {
-		t.Fatalf("SyncRoutes failed: %v", err)
-	}
+	seedStore(t, s, testDataset{routes: routes})
 
 	h := NewService(s)
 
@@ -148,3 +141,52 @@ func TestServiceStopsHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestServiceInfoHandler(t *testing.T) {
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	services := []lta.BusService{
+		{ServiceNo: "10", Operator: "SBST", Direction: 1, Category: "TRUNK", OriginCode: "75009", DestinationCode: "10009", AMPeakFreq: "08-11"},
+		{ServiceNo: "10", Operator: "SBST", Direction: 2, Category: "TRUNK", OriginCode: "10009", DestinationCode: "75009"},
+	}
+	seedStore(t, s, testDataset{services: services})
+
+	h := NewService(s)
+
+	t.Run("known service", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/services/10", nil)
+		req.SetPathValue("no", "10")
+		rec := httptest.NewRecorder()
+		h.Info(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+
+		var info ServiceInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if info.Operator != "SBST" || len(info.Directions) != 2 {
+			t.Fatalf("unexpected info: %+v", info)
+		}
+		if info.Directions[0].AMPeakFreq != "08-11" {
+			t.Errorf("expected AM peak 08-11, got %q", info.Directions[0].AMPeakFreq)
+		}
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/services/999", nil)
+		req.SetPathValue("no", "999")
+		rec := httptest.NewRecorder()
+		h.Info(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", rec.Code)
+		}
+	})
+}
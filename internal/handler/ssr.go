@@ -4,8 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/store"
 )
 
+// singaporeTime is used for clock times shown to riders. A fixed zone avoids
+// depending on tzdata in the container image.
+var singaporeTime = time.FixedZone("SGT", 8*60*60)
+
 // TemplateData is passed to every HTML template execution.
 type TemplateData struct {
 	StyleCSS string
@@ -27,6 +34,8 @@ type TemplateData struct {
 
 	Stop *StopRenderData
 
+	RetiredStop *RetiredStopRenderData
+
 	ServiceRoute *ServiceRouteRenderData
 
 	InitialState template.JS
@@ -40,6 +49,29 @@ type StopRenderData struct {
 	Latitude    float64         `json:"latitude"`
 	Longitude   float64         `json:"longitude"`
 	Services    []ServiceTiming `json:"services"`
+	// LiveArrivals is set when at least one service has a bus due; without
+	// it, Services lists what calls here but nothing is on its way.
+	LiveArrivals bool `json:"-"`
+
+	// Stale marks cached arrivals that could not be refreshed; AsOf is when
+	// they were fetched. Unavailable means no arrival data could be loaded.
+	Stale       bool       `json:"stale,omitempty"`
+	AsOf        *time.Time `json:"asOf,omitempty"`
+	Unavailable bool       `json:"unavailable,omitempty"`
+
+	Schedules    []ServiceSchedule `json:"schedules,omitempty"`
+	NextFirstBus string            `json:"nextFirstBus,omitempty"`
+}
+
+// RetiredStopRenderData describes a stop LTA no longer serves, for the 410
+// page at its old URL. Nearest is the closest stop still in service, if any
+// is within walking range of a bus.
+type RetiredStopRenderData struct {
+	Code        string
+	RoadName    string
+	Description string
+	RetiredAt   time.Time
+	Nearest     *store.StopWithDistance
 }
 
 // ServiceRouteRenderData carries bus route data for SSR and initial state hydration.
@@ -59,6 +91,11 @@ type ServiceRouteStop struct {
 	Sequence    int     `json:"sequence"`
 	Latitude    float64 `json:"latitude"`
 	Longitude   float64 `json:"longitude"`
+	store.BusTimes
+
+	// FirstBus and LastBus are today's times, formatted for SSR.
+	FirstBus string `json:"-"`
+	LastBus  string `json:"-"`
 }
 
 // BuildHomeJSONLD returns a WebSite + SearchAction JSON-LD script for the homepage.
@@ -322,6 +359,19 @@ func FormatArrival(v *int) string {
 	return fmt.Sprintf("%d", *v)
 }
 
+// FormatAsOf formats a data timestamp as a local Singapore clock time.
+func FormatAsOf(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.In(singaporeTime).Format("3:04 PM")
+}
+
+// FormatDate formats a date in Singapore time for template rendering.
+func FormatDate(t time.Time) string {
+	return t.In(singaporeTime).Format("2 Jan 2006")
+}
+
 // ArrivalClass returns the CSS class for an arrival time value.
 func ArrivalClass(v *int) string {
 	if v == nil || *v < 0 {
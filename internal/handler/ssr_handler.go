@@ -2,11 +2,11 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
-	"sort"
 	"time"
 
 	"github.com/aattwwss/yabatasg/internal/store"
@@ -36,36 +36,52 @@ func (h *StopPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
+	if stop.RetiredAt != nil {
+		h.serveRetired(w, stop)
+		return
+	}
 
 	data := h.base
 	now := time.Now()
 	var services []ServiceTiming
+	var asOf *time.Time
+	var stale, unavailable bool
 
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
 	defer cancel()
 	arrivals, err := h.lta.GetBusArrival(ctx, code, "")
 	if err == nil {
 		for _, svc := range arrivals.Services {
-			services = append(services, ServiceTiming{
-				ServiceNumber: svc.ServiceNumber,
-				Operator:      svc.Operator,
-				Next1:         new(DiffMinutes(svc.NextBus.EstimatedArrival.Time, now)),
-				Next2:         new(DiffMinutes(svc.NextBus2.EstimatedArrival.Time, now)),
-				Next3:         new(DiffMinutes(svc.NextBus3.EstimatedArrival.Time, now)),
-			})
+			services = append(services, newServiceTiming(svc, now))
 			if svc.Operator != "" {
 				if err := h.store.UpsertServiceOperator(svc.ServiceNumber, svc.Operator); err != nil {
 					slog.Warn("Failed to upsert operator", "serviceNo", svc.ServiceNumber, "error", err)
 				}
 			}
 		}
-		sort.Slice(services, func(i, j int) bool {
-			return serviceLess(services[i].ServiceNumber, services[j].ServiceNumber)
-		})
+		sortServiceTimings(services)
+		if arrivals.Stale {
+			stale = true
+			asOf = new(arrivals.AsOf)
+		}
 	} else {
 		slog.Warn("Failed to fetch arrivals for SSR", "code", code, "error", err)
+		unavailable = true
 	}
 
+	liveArrivals := len(services) > 0
+	routes, err := h.store.GetServicesAtStop(code)
+	if err != nil {
+		slog.Warn("Failed to get services at stop", "code", code, "error", err)
+	}
+	services = mergeStopServices(services, routes)
+
+	times, err := h.store.GetStopServiceTimes(code)
+	if err != nil {
+		slog.Warn("Failed to get first/last bus times", "code", code, "error", err)
+	}
+	schedules := buildStopSchedules(times, now)
+
 	data.Stop = &StopRenderData{
 		Code:        stop.Code,
 		RoadName:    stop.RoadName,
@@ -73,6 +89,14 @@ func (h *StopPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Latitude:    stop.Latitude,
 		Longitude:   stop.Longitude,
 		Services:    services,
+
+		LiveArrivals: liveArrivals,
+		Stale:        stale,
+		AsOf:         asOf,
+		Unavailable:  unavailable,
+
+		Schedules:    schedules,
+		NextFirstBus: nextFirstBus(schedules),
 	}
 
 	// Build title and descriptions that include the stop description for richer snippets.
@@ -103,3 +127,38 @@ func (h *StopPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
+
+// serveRetired answers 410 Gone for a stop LTA has retired, pointing riders
+// to the nearest stop still in service.
+func (h *StopPage) serveRetired(w http.ResponseWriter, stop *store.Stop) {
+	retired := &RetiredStopRenderData{
+		Code:        stop.Code,
+		RoadName:    stop.RoadName,
+		Description: stop.Description,
+		RetiredAt:   *stop.RetiredAt,
+	}
+	nearest, err := h.store.Nearby(stop.Latitude, stop.Longitude, 1)
+	if err != nil {
+		slog.Warn("Failed to find stop near retired stop", "code", stop.Code, "error", err)
+	} else if len(nearest) > 0 {
+		retired.Nearest = &nearest[0]
+	}
+
+	data := h.base
+	data.RetiredStop = retired
+	data.Title = fmt.Sprintf("Bus Stop %s — No Longer in Service | yabata Singapore", stop.Code)
+	data.Description = fmt.Sprintf("Bus stop %s (%s) has been retired by LTA.", stop.Code, stop.RoadName)
+	data.Canonical = fmt.Sprintf("https://yabatasg.com/stop/%s", stop.Code)
+	data.OGTitle = fmt.Sprintf("Bus Stop %s — No Longer in Service | yabata", stop.Code)
+	data.OGDescription = data.Description
+	data.OGURL = data.Canonical
+	// Keeps the client from loading arrivals for the stop over this page.
+	state, _ := json.Marshal(map[string]any{"code": stop.Code, "retired": true})
+	data.InitialState = template.JS(state)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	if err := h.tmpl.Execute(w, data); err != nil {
+		slog.Error("Template execution failed", "error", err)
+	}
+}
@@ -2,8 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"html/template"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
 )
 
 func TestBuildStopJSONLD(t *testing.T) {
@@ -212,3 +217,30 @@ func TestBuildBreadcrumbJSONLD(t *testing.T) {
 }
 
 func intPtr(v int) *int { return &v }
+
+func TestStopPageRetiredStop(t *testing.T) {
+	s := testStore(t)
+	stops := []lta.BusStop{
+		{BusStopCode: "11111", RoadName: "Road A", Latitude: 1.3, Longitude: 103.8},
+		{BusStopCode: "22222", RoadName: "Road A", Latitude: 1.301, Longitude: 103.8},
+		{BusStopCode: "33333", RoadName: "Road A", Latitude: 1.305, Longitude: 103.8},
+	}
+	seedStore(t, s, testDataset{stops: stops})
+	seedStore(t, s, testDataset{stops: stops[1:]})
+
+	tmpl := template.Must(template.New("t").Parse(
+		`{{with .RetiredStop}}retired {{.Code}}{{with .Nearest}}, nearest {{.Code}}{{end}}{{end}}`))
+	h := NewStopPage(s, &arrivalsMockLTA{err: errors.New("not called")}, TemplateData{}, tmpl)
+
+	req := httptest.NewRequest("GET", "/stop/11111", nil)
+	req.SetPathValue("code", "11111")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGone {
+		t.Fatalf("expected 410, got %d", rec.Code)
+	}
+	if got, want := rec.Body.String(), "retired 11111, nearest 22222"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// StopSearch serves full-text stop search at /api/v1/stops/search?q=.
+type StopSearch struct {
+	store *store.Store
+}
+
+func NewStopSearch(s *store.Store) *StopSearch {
+	return &StopSearch{store: s}
+}
+
+func (h *StopSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeJSON(w, http.StatusOK, []store.Stop{})
+		return
+	}
+
+	limit := 20
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 && n <= 50 {
+			limit = n
+		}
+	}
+
+	results, err := h.store.SearchStops(q, limit)
+	if err != nil {
+		slog.Error("Error searching stops", "q", q, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search stops"})
+		return
+	}
+	if results == nil {
+		results = []store.Stop{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
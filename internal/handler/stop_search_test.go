@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+func TestStopSearchHandler(t *testing.T) {
+	s := seededStore(t, testDataset{
+		stops: []lta.BusStop{
+			{BusStopCode: "53241", RoadName: "Bishan St 13", Description: "Opp Blk 123"},
+			{BusStopCode: "01012", RoadName: "Victoria St", Description: "Hotel Grand Pacific"},
+		},
+	})
+	h := NewStopSearch(s)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"opposite block", 1},
+		{"victoria", 1},
+		{"st", 2},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/stops/search?q="+url.QueryEscape(tt.query), nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("q=%q: expected 200, got %d", tt.query, rec.Code)
+		}
+		var results []store.Stop
+		if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+			t.Fatalf("q=%q: failed to decode: %v", tt.query, err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("q=%q: expected %d results, got %+v", tt.query, tt.want, results)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// StopServices serves /api/v1/stops/{code}/services: every service calling
+// at a stop according to the route data, with no live lookup.
+type StopServices struct {
+	store *store.Store
+}
+
+func NewStopServices(s *store.Store) *StopServices {
+	return &StopServices{store: s}
+}
+
+func (h *StopServices) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "stop code is required"})
+		return
+	}
+
+	stop, err := h.store.GetStop(code)
+	if err != nil {
+		slog.Error("Failed to get stop", "code", code, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get services"})
+		return
+	}
+	if stop == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "stop not found"})
+		return
+	}
+
+	services, err := h.store.GetServicesAtStop(code)
+	if err != nil {
+		slog.Error("Failed to get services at stop", "code", code, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get services"})
+		return
+	}
+	if services == nil {
+		services = []store.StopService{}
+	}
+	sortStopServices(services)
+
+	writeJSON(w, http.StatusOK, services)
+}
+
+func sortStopServices(services []store.StopService) {
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if a.ServiceNo != b.ServiceNo {
+			return serviceLess(a.ServiceNo, b.ServiceNo)
+		}
+		if a.Direction != b.Direction {
+			return a.Direction < b.Direction
+		}
+		return a.Sequence < b.Sequence
+	})
+}
+
+// mergeStopServices lists every service at a stop: the live timings, plus
+// a timing with no ETAs for each routed service live data didn't mention.
+// Destinations are filled in from the route data; a service calling in
+// both directions takes its first.
+func mergeStopServices(live []ServiceTiming, routes []store.StopService) []ServiceTiming {
+	dest := make(map[string]store.StopService)
+	for _, rs := range routes {
+		if _, ok := dest[rs.ServiceNo]; !ok {
+			dest[rs.ServiceNo] = rs
+		}
+	}
+
+	out := make([]ServiceTiming, 0, max(len(live), len(dest)))
+	seen := make(map[string]bool)
+	for _, st := range live {
+		if rs, ok := dest[st.ServiceNumber]; ok {
+			st.Destination = rs.DestinationName
+		}
+		seen[st.ServiceNumber] = true
+		out = append(out, st)
+	}
+	for no, rs := range dest {
+		if seen[no] {
+			continue
+		}
+		out = append(out, ServiceTiming{
+			ServiceNumber: no,
+			Operator:      rs.Operator,
+			Destination:   rs.DestinationName,
+		})
+	}
+	sortServiceTimings(out)
+	return out
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+var stopServicesDataset = testDataset{
+	stops: []lta.BusStop{
+		{BusStopCode: "12345", RoadName: "Road A", Description: "Stop A"},
+		{BusStopCode: "99999", RoadName: "Road Z", Description: "Terminal"},
+	},
+	routes: []lta.BusRoute{
+		{ServiceNo: "196", Direction: 1, StopSequence: 1, BusStopCode: "12345"},
+		{ServiceNo: "196", Direction: 1, StopSequence: 2, BusStopCode: "99999"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "12345"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "99999"},
+		{ServiceNo: "57", Direction: 2, StopSequence: 3, BusStopCode: "12345"},
+		{ServiceNo: "57", Direction: 2, StopSequence: 4, BusStopCode: "99999"},
+	},
+}
+
+func TestStopServicesHandler(t *testing.T) {
+	h := NewStopServices(seededStore(t, stopServicesDataset))
+
+	req := httptest.NewRequest("GET", "/api/v1/stops/12345/services", nil)
+	req.SetPathValue("code", "12345")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var services []store.StopService
+	if err := json.NewDecoder(rec.Body).Decode(&services); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	var got []string
+	for _, s := range services {
+		got = append(got, s.ServiceNo)
+	}
+	if strings.Join(got, ",") != "10,57,196" {
+		t.Errorf("expected services in numeric order, got %v", got)
+	}
+	if services[1].Direction != 2 || services[1].Sequence != 3 || services[1].DestinationName != "Terminal" {
+		t.Errorf("unexpected entry for 57: %+v", services[1])
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/stops/00000/services", nil)
+	req.SetPathValue("code", "00000")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown stop, got %d", rec.Code)
+	}
+}
+
+func TestStopPageListsServicesWithoutLiveData(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(template.FuncMap{"formatArrival": FormatArrival}).Parse(
+		`{{range .Stop.Services}}{{.ServiceNumber}}={{formatArrival .Next1}} {{end}}|live={{.Stop.LiveArrivals}}`))
+	h := NewStopPage(seededStore(t, stopServicesDataset), &arrivalsMockLTA{err: errors.New("boom")}, TemplateData{}, tmpl)
+
+	req := httptest.NewRequest("GET", "/stop/12345", nil)
+	req.SetPathValue("code", "12345")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got, want := rec.Body.String(), "10=-- 57=-- 196=-- |live=false"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeStopServices(t *testing.T) {
+	live := []ServiceTiming{{ServiceNumber: "196", Operator: "SMRT", Next1: new(3)}}
+	routes := []store.StopService{
+		{ServiceNo: "10", Operator: "SBST", Direction: 1, DestinationName: "Kent Ridge"},
+		{ServiceNo: "196", Operator: "SMRT", Direction: 1, DestinationName: "Clementi Int"},
+		{ServiceNo: "196", Operator: "SMRT", Direction: 2, DestinationName: "Marina Ctr"},
+	}
+
+	got := mergeStopServices(live, routes)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 services, got %+v", got)
+	}
+	if got[0].ServiceNumber != "10" || got[0].Next1 != nil || got[0].Destination != "Kent Ridge" {
+		t.Errorf("expected 10 with no ETA, got %+v", got[0])
+	}
+	if got[1].ServiceNumber != "196" || got[1].Next1 == nil || *got[1].Next1 != 3 || got[1].Destination != "Clementi Int" {
+		t.Errorf("expected live 196 to Clementi Int, got %+v", got[1])
+	}
+}
@@ -28,11 +28,23 @@ func NewStopDetail(client StopDetailClient, s *store.Store) *StopDetail {
 type StopArrivalResponse struct {
 	BusStopCode string          `json:"busStopCode"`
 	Services    []ServiceTiming `json:"services"`
+	Stale       bool            `json:"stale,omitempty"`
+	AsOf        *time.Time      `json:"asOf,omitempty"`
+
+	// Schedules lists today's first/last bus for every service calling at
+	// the stop. NextFirstBus is set once they have all finished for the
+	// night.
+	Schedules    []ServiceSchedule `json:"schedules,omitempty"`
+	NextFirstBus string            `json:"nextFirstBus,omitempty"`
 }
 
+// ServiceTiming is a service's next three buses in minutes. On the stop
+// page the Next fields are nil for services with no live data, and
+// Destination is filled in from the route data.
 type ServiceTiming struct {
 	ServiceNumber string `json:"serviceNo"`
 	Operator      string `json:"operator"`
+	Destination   string `json:"destination,omitempty"`
 	Next1         *int   `json:"next1"`
 	Next2         *int   `json:"next2"`
 	Next3         *int   `json:"next3"`
@@ -51,24 +63,18 @@ func (h *StopDetail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	arrivals, err := h.lta.GetBusArrival(r.Context(), code, "")
 	if err != nil {
 		slog.Error("Error getting bus arrivals for stop", "code", code, "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch arrivals"})
+		writeUpstreamError(w, err, "Failed to fetch arrivals")
 		return
 	}
 
 	resp := StopArrivalResponse{
 		BusStopCode: arrivals.BusStopCode,
 	}
+	resp.markStale(arrivals)
 
 	now := time.Now()
 	for _, svc := range arrivals.Services {
-		resp.Services = append(resp.Services, ServiceTiming{
-			ServiceNumber: svc.ServiceNumber,
-			Operator:      svc.Operator,
-			Next1:         new(DiffMinutes(svc.NextBus.EstimatedArrival.Time, now)),
-			Next2:         new(DiffMinutes(svc.NextBus2.EstimatedArrival.Time, now)),
-			Next3:         new(DiffMinutes(svc.NextBus3.EstimatedArrival.Time, now)),
-		})
+		resp.Services = append(resp.Services, newServiceTiming(svc, now))
 		if svc.Operator != "" {
 			if err := h.store.UpsertServiceOperator(svc.ServiceNumber, svc.Operator); err != nil {
 				slog.Warn("Failed to upsert operator", "serviceNo", svc.ServiceNumber, "error", err)
@@ -76,15 +82,54 @@ func (h *StopDetail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	sort.Slice(resp.Services, func(i, j int) bool {
-		return serviceLess(resp.Services[i].ServiceNumber, resp.Services[j].ServiceNumber)
-	})
+	sortServiceTimings(resp.Services)
+
+	times, err := h.store.GetStopServiceTimes(code)
+	if err != nil {
+		slog.Warn("Failed to get first/last bus times", "code", code, "error", err)
+	}
+	resp.Schedules = buildStopSchedules(times, now)
+	resp.NextFirstBus = nextFirstBus(resp.Schedules)
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		slog.Error("Error encoding response", "error", err)
 	}
 }
 
+// markStale flags the response when the arrivals are cached data that could
+// not be refreshed, so clients can show how old the data is.
+func (resp *StopArrivalResponse) markStale(arrivals *lta.BusArrival) {
+	if !arrivals.Stale {
+		return
+	}
+	resp.Stale = true
+	resp.AsOf = new(arrivals.AsOf)
+}
+
+// newServiceTiming reduces an upstream service entry to minutes until each of
+// the next three buses, relative to now.
+func newServiceTiming(svc lta.Service, now time.Time) ServiceTiming {
+	return ServiceTiming{
+		ServiceNumber: svc.ServiceNumber,
+		Operator:      svc.Operator,
+		Next1:         new(DiffMinutes(svc.NextBus.EstimatedArrival.Time, now)),
+		Next2:         new(DiffMinutes(svc.NextBus2.EstimatedArrival.Time, now)),
+		Next3:         new(DiffMinutes(svc.NextBus3.EstimatedArrival.Time, now)),
+	}
+}
+
+func sortServiceTimings(services []ServiceTiming) {
+	sortByService(services, func(st ServiceTiming) string { return st.ServiceNumber })
+}
+
+// sortByService orders items by service number with serviceLess, so every
+// arrival response lists services the same way.
+func sortByService[T any](items []T, serviceNo func(T) string) {
+	sort.Slice(items, func(i, j int) bool {
+		return serviceLess(serviceNo(items[i]), serviceNo(items[j]))
+	})
+}
+
 func serviceLess(a, b string) bool {
 	aNum, aSfx := splitService(a)
 	bNum, bSfx := splitService(b)
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aattwwss/yabatasg/internal/lta"
 	"github.com/aattwwss/yabatasg/internal/store"
@@ -23,6 +25,65 @@ func (m *mockLTA) GetBusArrival(ctx context.Context, busStopCode, serviceNumber
 	}, nil
 }
 
+// arrivalsMockLTA answers arrival lookups from canned per-stop responses.
+type arrivalsMockLTA struct {
+	// services lists what each stop reports. Stops not listed report
+	// anyStop, or fail with lta.ErrCircuitOpen when it is nil.
+	services map[string][]lta.Service
+	anyStop  []lta.Service
+	// errs fails lookups at individual stops; err fails every lookup.
+	errs map[string]error
+	err  error
+	// stale marks every response as cached data from asOf.
+	stale bool
+	asOf  time.Time
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *arrivalsMockLTA) GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*lta.BusArrival, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	if err := m.errs[busStopCode]; err != nil {
+		return nil, err
+	}
+	svcs, ok := m.services[busStopCode]
+	if !ok {
+		if m.anyStop == nil {
+			return nil, lta.ErrCircuitOpen
+		}
+		svcs = m.anyStop
+	}
+	var out []lta.Service
+	for _, svc := range svcs {
+		if serviceNumber == "" || svc.ServiceNumber == serviceNumber {
+			out = append(out, svc)
+		}
+	}
+	return &lta.BusArrival{BusStopCode: busStopCode, Services: out, AsOf: m.asOf, Stale: m.stale}, nil
+}
+
+// setServices changes what busStopCode reports from the next lookup on.
+func (m *arrivalsMockLTA) setServices(busStopCode string, svcs []lta.Service) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.services == nil {
+		m.services = make(map[string][]lta.Service)
+	}
+	m.services[busStopCode] = svcs
+}
+
+func (m *arrivalsMockLTA) callCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
 func testStore(t *testing.T) *store.Store {
 	t.Helper()
 	s, err := store.New(":memory:")
@@ -33,6 +94,64 @@ func testStore(t *testing.T) *store.Store {
 	return s
 }
 
+// testDataset is what seedStore publishes. Nil fields keep what the live
+// dataset has.
+type testDataset struct {
+	stops    []lta.BusStop
+	routes   []lta.BusRoute
+	services []lta.BusService
+	// runs are recorded as finished sync runs, oldest first.
+	runs []store.SyncResult
+}
+
+// seedStore publishes ds into s as one dataset, the way the syncer does.
+func seedStore(t *testing.T, s *store.Store, ds testDataset) {
+	t.Helper()
+	b, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Discard()
+	if ds.stops != nil {
+		if _, err := b.Sync(ds.stops); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ds.routes != nil {
+		if _, err := b.SyncRoutes(ds.routes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ds.services != nil {
+		if err := b.SyncServices(ds.services); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.SeedServiceOperators(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(); err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range ds.runs {
+		id, err := s.StartSyncRun()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.FinishSyncRun(id, res, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// seededStore returns a new store holding ds.
+func seededStore(t *testing.T, ds testDataset) *store.Store {
+	t.Helper()
+	s := testStore(t)
+	seedStore(t, s, ds)
+	return s
+}
+
 func TestStopDetailHandler(t *testing.T) {
 	h := NewStopDetail(&mockLTA{}, testStore(t))
 
@@ -91,3 +210,49 @@ func TestStopDetailHandlerMissingCode(t *testing.T) {
 		t.Errorf("expected 400, got %d", rec.Code)
 	}
 }
+
+func TestStopDetailHandlerStale(t *testing.T) {
+	asOf := time.Date(2024, 10, 12, 14, 20, 0, 0, time.UTC)
+	h := NewStopDetail(&arrivalsMockLTA{
+		anyStop: []lta.Service{{ServiceNumber: "10", Operator: "SBST"}},
+		stale:   true,
+		asOf:    asOf,
+	}, testStore(t))
+
+	req := httptest.NewRequest("GET", "/api/v1/stops/12345/arrivals", nil)
+	req.SetPathValue("code", "12345")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp StopArrivalResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if !resp.Stale {
+		t.Error("expected stale marker")
+	}
+	if resp.AsOf == nil || !resp.AsOf.Equal(asOf) {
+		t.Errorf("expected asOf %v, got %v", asOf, resp.AsOf)
+	}
+	if len(resp.Services) != 1 {
+		t.Errorf("expected stale services to be returned, got %d", len(resp.Services))
+	}
+}
+
+func TestStopDetailHandlerFreshOmitsStale(t *testing.T) {
+	h := NewStopDetail(&mockLTA{}, testStore(t))
+
+	req := httptest.NewRequest("GET", "/api/v1/stops/12345/arrivals", nil)
+	req.SetPathValue("code", "12345")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var raw map[string]any
+	json.NewDecoder(rec.Body).Decode(&raw)
+	if _, ok := raw["stale"]; ok {
+		t.Error("fresh response should not include stale")
+	}
+	if _, ok := raw["asOf"]; ok {
+		t.Error("fresh response should not include asOf")
+	}
+}
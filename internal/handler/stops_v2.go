@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// StopDetailV2 serves /api/v2/stops/{code}/arrivals. Unlike v1, which
+// reduces each bus to whole minutes, it keeps everything DataMall reports
+// about the next three buses.
+type StopDetailV2 struct {
+	lta StopDetailClient
+}
+
+func NewStopDetailV2(client StopDetailClient) *StopDetailV2 {
+	return &StopDetailV2{lta: client}
+}
+
+type StopArrivalResponseV2 struct {
+	BusStopCode string             `json:"busStopCode"`
+	Services    []ServiceArrivalV2 `json:"services"`
+	Stale       bool               `json:"stale,omitempty"`
+	AsOf        *time.Time         `json:"asOf,omitempty"`
+}
+
+type ServiceArrivalV2 struct {
+	ServiceNumber string          `json:"serviceNo"`
+	Operator      string          `json:"operator"`
+	Buses         []NextBusDetail `json:"buses"`
+}
+
+// NextBusDetail is one upcoming bus. Estimated is true when the ETA comes
+// from the bus's live position and false when it is only the timetable
+// (DataMall's Monitored flag); Latitude and Longitude are only set for
+// monitored buses.
+type NextBusDetail struct {
+	EstimatedArrival time.Time `json:"estimatedArrival"`
+	ETASeconds       int       `json:"etaSeconds"`
+	Estimated        bool      `json:"estimated"`
+
+	// Load is SEA (seats available), SDA (standing available) or LSD
+	// (limited standing).
+	Load string `json:"load"`
+	// Type is SD (single deck), DD (double deck) or BD (bendy).
+	Type                 string `json:"type"`
+	Feature              string `json:"feature"`
+	WheelchairAccessible bool   `json:"wheelchairAccessible"`
+
+	Latitude        *float64 `json:"latitude,omitempty"`
+	Longitude       *float64 `json:"longitude,omitempty"`
+	OriginCode      string   `json:"originCode"`
+	DestinationCode string   `json:"destinationCode"`
+	VisitNumber     int      `json:"visitNumber"`
+}
+
+func (h *StopDetailV2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	code := r.PathValue("code")
+	if code == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "stop code is required"})
+		return
+	}
+
+	arrivals, err := h.lta.GetBusArrival(r.Context(), code, "")
+	if err != nil {
+		slog.Error("Error getting bus arrivals for stop", "code", code, "error", err)
+		writeUpstreamError(w, err, "Failed to fetch arrivals")
+		return
+	}
+
+	resp := StopArrivalResponseV2{
+		BusStopCode: arrivals.BusStopCode,
+		Services:    []ServiceArrivalV2{},
+	}
+	if arrivals.Stale {
+		resp.Stale = true
+		resp.AsOf = new(arrivals.AsOf)
+	}
+
+	now := time.Now()
+	for _, svc := range arrivals.Services {
+		sa := ServiceArrivalV2{
+			ServiceNumber: svc.ServiceNumber,
+			Operator:      svc.Operator,
+			Buses:         []NextBusDetail{},
+		}
+		for _, nb := range []lta.NextBus{svc.NextBus, svc.NextBus2, svc.NextBus3} {
+			// DataMall sends empty slots when fewer than three buses are due.
+			if nb.EstimatedArrival.IsZero() {
+				continue
+			}
+			sa.Buses = append(sa.Buses, newNextBusDetail(nb, now))
+		}
+		resp.Services = append(resp.Services, sa)
+	}
+
+	sortByService(resp.Services, func(sa ServiceArrivalV2) string { return sa.ServiceNumber })
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+func newNextBusDetail(nb lta.NextBus, now time.Time) NextBusDetail {
+	d := NextBusDetail{
+		EstimatedArrival:     nb.EstimatedArrival.Time,
+		ETASeconds:           int(nb.EstimatedArrival.Sub(now).Seconds()),
+		Estimated:            nb.Monitored == 1,
+		Load:                 nb.Load,
+		Type:                 nb.Type,
+		Feature:              nb.Feature,
+		WheelchairAccessible: nb.Feature == "WAB",
+		OriginCode:           nb.OriginCode,
+		DestinationCode:      nb.DestinationCode,
+	}
+	d.VisitNumber, _ = strconv.Atoi(nb.VisitNumber)
+	if lat, lng, ok := parseBusPosition(nb); ok {
+		d.Latitude, d.Longitude = &lat, &lng
+	}
+	return d
+}
+
+// parseBusPosition returns the bus's reported coordinates, or false when
+// DataMall doesn't know where it is (unmonitored buses report "0.0").
+func parseBusPosition(nb lta.NextBus) (lat, lng float64, ok bool) {
+	lat, err := strconv.ParseFloat(nb.Latitude, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lng, err = strconv.ParseFloat(nb.Longitude, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if lat == 0 && lng == 0 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// richServices reports 196 with one live and one scheduled bus, and 10
+// with none.
+func richServices(now time.Time) []lta.Service {
+	return []lta.Service{
+		{
+			ServiceNumber: "196",
+			Operator:      "SMRT",
+			NextBus: lta.NextBus{
+				OriginCode: "10009", DestinationCode: "77009",
+				EstimatedArrival: lta.SafeTime{Time: now.Add(90 * time.Second)},
+				Monitored:        1, Latitude: "1.3154", Longitude: "103.9054",
+				VisitNumber: "1", Load: "SEA", Feature: "WAB", Type: "DD",
+			},
+			NextBus2: lta.NextBus{
+				EstimatedArrival: lta.SafeTime{Time: now.Add(10 * time.Minute)},
+				Monitored:        0, Latitude: "0.0", Longitude: "0.0",
+				VisitNumber: "2", Load: "SDA", Type: "SD",
+			},
+		},
+		{ServiceNumber: "10", Operator: "SBST"},
+	}
+}
+
+func TestStopDetailV2Handler(t *testing.T) {
+	h := NewStopDetailV2(&arrivalsMockLTA{anyStop: richServices(time.Now())})
+
+	req := httptest.NewRequest("GET", "/api/v2/stops/12345/arrivals", nil)
+	req.SetPathValue("code", "12345")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp StopArrivalResponseV2
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Services) != 2 || resp.Services[0].ServiceNumber != "10" {
+		t.Fatalf("expected services sorted 10, 196, got %+v", resp.Services)
+	}
+	if len(resp.Services[0].Buses) != 0 {
+		t.Errorf("expected no buses for 10, got %d", len(resp.Services[0].Buses))
+	}
+
+	buses := resp.Services[1].Buses
+	if len(buses) != 2 {
+		t.Fatalf("expected 2 buses for 196, got %d", len(buses))
+	}
+
+	first := buses[0]
+	if first.ETASeconds < 85 || first.ETASeconds > 90 {
+		t.Errorf("expected ETA ~90s, got %d", first.ETASeconds)
+	}
+	if !first.Estimated || first.Load != "SEA" || first.Type != "DD" || !first.WheelchairAccessible {
+		t.Errorf("unexpected first bus: %+v", first)
+	}
+	if first.Latitude == nil || *first.Latitude != 1.3154 || first.VisitNumber != 1 {
+		t.Errorf("unexpected position/visit: %+v", first)
+	}
+	if first.OriginCode != "10009" || first.DestinationCode != "77009" {
+		t.Errorf("unexpected origin/destination: %+v", first)
+	}
+
+	second := buses[1]
+	if second.Estimated {
+		t.Error("expected second bus to be scheduled, not estimated")
+	}
+	if second.Latitude != nil || second.Longitude != nil {
+		t.Error("expected no position for an unmonitored bus")
+	}
+}
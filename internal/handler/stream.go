@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+const (
+	// streamPollInterval matches the arrival cache TTL in lta.Client, so most
+	// polls find an expired entry and revalidate it. lta.Client only marks
+	// such data stale once a refresh fails, so revalidation alone never
+	// flips the stale flag sent to subscribers.
+	streamPollInterval = 10 * time.Second
+	streamHeartbeat    = 15 * time.Second
+	// streamBuffer is how many undelivered events a subscriber may queue before
+	// it is dropped; the client reconnects and receives a fresh snapshot.
+	streamBuffer = 8
+)
+
+// ArrivalStream pushes live arrival updates for a stop as Server-Sent Events.
+// One upstream poller runs per stop and is shared by all of its subscribers;
+// it starts with the first subscriber and stops when the last one leaves.
+type ArrivalStream struct {
+	lta      LTAClient
+	interval time.Duration
+
+	mu     sync.Mutex
+	feeds  map[string]*stopFeed
+	closed bool
+}
+
+type stopFeed struct {
+	code   string
+	subs   map[*streamSub]struct{}
+	last   []ServiceTiming
+	stale  *lta.BusArrival
+	ready  bool
+	cancel context.CancelFunc
+}
+
+type streamSub struct {
+	ch     chan streamEvent
+	primed bool
+}
+
+type streamEvent struct {
+	name string
+	data any
+}
+
+// ArrivalUpdate is the payload of an "update" event: the services whose
+// timings changed since the previous event, and those no longer listed.
+// Stale is always present so clients can clear a previous stale marker.
+type ArrivalUpdate struct {
+	BusStopCode string          `json:"busStopCode"`
+	Updated     []ServiceTiming `json:"updated"`
+	Removed     []string        `json:"removed"`
+	Stale       bool            `json:"stale"`
+	AsOf        *time.Time      `json:"asOf,omitempty"`
+}
+
+func NewArrivalStream(client LTAClient) *ArrivalStream {
+	return &ArrivalStream{
+		lta:      client,
+		interval: streamPollInterval,
+		feeds:    make(map[string]*stopFeed),
+	}
+}
+
+func (h *ArrivalStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "stop code is required"})
+		return
+	}
+
+	sub := h.subscribe(code)
+	if sub == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server is shutting down"})
+		return
+	}
+	defer h.unsubscribe(code, sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Warn("Arrival stream: flushing not supported", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev.data)
+			if err != nil {
+				slog.Error("Arrival stream: failed to encode event", "code", code, "error", err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close ends every open stream and stops all pollers. It is meant to be
+// registered with http.Server.RegisterOnShutdown so long-lived connections
+// don't hold up graceful shutdown.
+func (h *ArrivalStream) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for code, f := range h.feeds {
+		f.cancel()
+		for sub := range f.subs {
+			close(sub.ch)
+		}
+		delete(h.feeds, code)
+	}
+}
+
+func (h *ArrivalStream) subscribe(code string) *streamSub {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+
+	sub := &streamSub{ch: make(chan streamEvent, streamBuffer)}
+	f, ok := h.feeds[code]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &stopFeed{code: code, subs: make(map[*streamSub]struct{}), cancel: cancel}
+		h.feeds[code] = f
+		go h.poll(ctx, f)
+	}
+	f.subs[sub] = struct{}{}
+
+	// Late joiners get the current snapshot straight away rather than
+	// waiting for the next poll.
+	if f.ready {
+		sub.ch <- snapshotEvent(code, f.last, f.stale)
+		sub.primed = true
+	}
+	return sub
+}
+
+func (h *ArrivalStream) unsubscribe(code string, sub *streamSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, ok := h.feeds[code]
+	if !ok {
+		return
+	}
+	if _, ok := f.subs[sub]; !ok {
+		return
+	}
+	delete(f.subs, sub)
+	close(sub.ch)
+	if len(f.subs) == 0 {
+		f.cancel()
+		delete(h.feeds, code)
+	}
+}
+
+func (h *ArrivalStream) poll(ctx context.Context, f *stopFeed) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.refresh(ctx, f)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *ArrivalStream) refresh(ctx context.Context, f *stopFeed) {
+	arrivals, err := h.lta.GetBusArrival(ctx, f.code, "")
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Arrival stream: failed to fetch arrivals", "code", f.code, "error", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	services := make([]ServiceTiming, 0, len(arrivals.Services))
+	for _, svc := range arrivals.Services {
+		services = append(services, newServiceTiming(svc, now))
+	}
+	sortServiceTimings(services)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	update := diffServiceTimings(f.code, f.last, services)
+	staleChanged := arrivals.Stale != (f.stale != nil)
+	if arrivals.Stale {
+		update.Stale = true
+		update.AsOf = new(arrivals.AsOf)
+		f.stale = arrivals
+	} else {
+		f.stale = nil
+	}
+	f.last = services
+	f.ready = true
+
+	for sub := range f.subs {
+		var ev streamEvent
+		switch {
+		case !sub.primed:
+			ev = snapshotEvent(f.code, services, f.stale)
+		case len(update.Updated) > 0 || len(update.Removed) > 0 || staleChanged:
+			ev = streamEvent{name: "update", data: update}
+		default:
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+			sub.primed = true
+		default:
+			// Subscriber isn't keeping up; drop it so it reconnects cleanly.
+			delete(f.subs, sub)
+			close(sub.ch)
+		}
+	}
+	if len(f.subs) == 0 {
+		f.cancel()
+		delete(h.feeds, f.code)
+	}
+}
+
+// snapshotEvent builds the full-state event sent to a new subscriber. stale is
+// the last arrival payload when it was served stale, or nil.
+func snapshotEvent(code string, services []ServiceTiming, stale *lta.BusArrival) streamEvent {
+	if services == nil {
+		services = []ServiceTiming{}
+	}
+	resp := StopArrivalResponse{BusStopCode: code, Services: services}
+	if stale != nil {
+		resp.markStale(stale)
+	}
+	return streamEvent{name: "snapshot", data: resp}
+}
+
+// diffServiceTimings reports which services in cur differ from prev, and which
+// services in prev are missing from cur.
+func diffServiceTimings(code string, prev, cur []ServiceTiming) ArrivalUpdate {
+	update := ArrivalUpdate{BusStopCode: code, Updated: []ServiceTiming{}, Removed: []string{}}
+
+	old := make(map[string]ServiceTiming, len(prev))
+	for _, st := range prev {
+		old[st.ServiceNumber] = st
+	}
+	for _, st := range cur {
+		o, ok := old[st.ServiceNumber]
+		if !ok || !sameTiming(o, st) {
+			update.Updated = append(update.Updated, st)
+		}
+		delete(old, st.ServiceNumber)
+	}
+	for no := range old {
+		update.Removed = append(update.Removed, no)
+	}
+	sort.Strings(update.Removed)
+	return update
+}
+
+func sameTiming(a, b ServiceTiming) bool {
+	return a.Operator == b.Operator &&
+		sameMinutes(a.Next1, b.Next1) &&
+		sameMinutes(a.Next2, b.Next2) &&
+		sameMinutes(a.Next3, b.Next3)
+}
+
+func sameMinutes(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// dueAt reports service 10 arriving at eta.
+func dueAt(eta time.Time) lta.Service {
+	return lta.Service{ServiceNumber: "10", Operator: "SBST", NextBus: lta.NextBus{EstimatedArrival: lta.SafeTime{Time: eta}}}
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvent(t *testing.T, sc *bufio.Scanner) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if ev.name != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	t.Fatalf("stream ended before event: %v", sc.Err())
+	return ev
+}
+
+func TestArrivalStreamSnapshotThenDiff(t *testing.T) {
+	now := time.Now()
+	client := &arrivalsMockLTA{services: map[string][]lta.Service{
+		"12345": {dueAt(now.Add(2*time.Minute + 30*time.Second)), {ServiceNumber: "196", Operator: "SMRT"}},
+	}}
+	h := NewArrivalStream(client)
+	h.interval = 20 * time.Millisecond
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/stops/{code}/arrivals/stream", h)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	defer h.Close()
+
+	res, err := http.Get(srv.URL + "/api/v1/stops/12345/arrivals/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+
+	sc := bufio.NewScanner(res.Body)
+
+	ev := readEvent(t, sc)
+	if ev.name != "snapshot" {
+		t.Fatalf("expected snapshot first, got %q", ev.name)
+	}
+	var snap StopArrivalResponse
+	if err := json.Unmarshal([]byte(ev.data), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.BusStopCode != "12345" || len(snap.Services) != 2 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+
+	// 10 is now two minutes later and 196 no longer runs.
+	client.setServices("12345", []lta.Service{dueAt(now.Add(4*time.Minute + 30*time.Second))})
+
+	ev = readEvent(t, sc)
+	if ev.name != "update" {
+		t.Fatalf("expected update, got %q", ev.name)
+	}
+	var upd ArrivalUpdate
+	if err := json.Unmarshal([]byte(ev.data), &upd); err != nil {
+		t.Fatal(err)
+	}
+	if len(upd.Updated) != 1 || upd.Updated[0].ServiceNumber != "10" {
+		t.Errorf("expected service 10 updated, got %+v", upd.Updated)
+	}
+	if len(upd.Removed) != 1 || upd.Removed[0] != "196" {
+		t.Errorf("expected service 196 removed, got %+v", upd.Removed)
+	}
+}
+
+// expiredCache hands out every entry as already expired, so each poll of
+// the stream revalidates it.
+type expiredCache struct {
+	mu      sync.Mutex
+	entries map[string]lta.CacheEntry
+}
+
+func (c *expiredCache) Get(key string) (*lta.CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e.ExpiresAt = time.Now().Add(-time.Millisecond)
+	return &e, true
+}
+
+func (c *expiredCache) Peek(key string) (*lta.CacheEntry, bool) {
+	return c.Get(key)
+}
+
+func (c *expiredCache) Set(key string, entry *lta.CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = *entry
+}
+
+func (c *expiredCache) Prune() int            { return 0 }
+func (c *expiredCache) Stats() lta.CacheStats { return lta.CacheStats{} }
+
+func TestArrivalStreamRevalidationIsNotStale(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eta := time.Now().Add(5 * time.Minute).Format(time.RFC3339)
+		w.Write([]byte(`{"BusStopCode":"12345","Services":[{"ServiceNo":"10","Operator":"SBST","NextBus":{"EstimatedArrival":"` + eta + `"}}]}`))
+	}))
+	defer upstream.Close()
+
+	client := lta.New("test-api-key", upstream.URL, lta.WithCache(&expiredCache{entries: make(map[string]lta.CacheEntry)}))
+	h := NewArrivalStream(client)
+	h.interval = 20 * time.Millisecond
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/stops/{code}/arrivals/stream", h)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	defer h.Close()
+
+	res, err := http.Get(srv.URL + "/api/v1/stops/12345/arrivals/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		sc := bufio.NewScanner(res.Body)
+		var ev sseEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "" && ev.name != "":
+				events <- ev
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	// Let the feed poll, and so revalidate, many times over.
+	timeout := time.After(300 * time.Millisecond)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("stream ended early")
+			}
+			if strings.Contains(ev.data, `"stale":true`) {
+				t.Fatalf("healthy upstream produced a stale %s event: %s", ev.name, ev.data)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestArrivalStreamSharedPoller(t *testing.T) {
+	client := &arrivalsMockLTA{anyStop: []lta.Service{dueAt(time.Now().Add(5 * time.Minute))}}
+	h := NewArrivalStream(client)
+	h.interval = time.Hour
+
+	a := h.subscribe("12345")
+	<-a.ch
+	b := h.subscribe("12345")
+	ev := <-b.ch
+	if ev.name != "snapshot" {
+		t.Errorf("late subscriber expected snapshot, got %q", ev.name)
+	}
+
+	if calls := client.callCount(); calls != 1 {
+		t.Errorf("expected 1 upstream call for 2 subscribers, got %d", calls)
+	}
+
+	h.unsubscribe("12345", a)
+	h.unsubscribe("12345", b)
+	h.mu.Lock()
+	n := len(h.feeds)
+	h.mu.Unlock()
+	if n != 0 {
+		t.Errorf("expected feed to stop after last subscriber left, got %d feeds", n)
+	}
+}
+
+func TestArrivalStreamClose(t *testing.T) {
+	h := NewArrivalStream(&arrivalsMockLTA{anyStop: []lta.Service{dueAt(time.Now().Add(5 * time.Minute))}})
+	h.interval = time.Hour
+
+	sub := h.subscribe("12345")
+	h.Close()
+
+	for range sub.ch {
+	}
+	if h.subscribe("12345") != nil {
+		t.Error("expected subscribe to fail after Close")
+	}
+}
+
+func TestDiffServiceTimings(t *testing.T) {
+	prev := []ServiceTiming{
+		{ServiceNumber: "10", Next1: intPtr(3)},
+		{ServiceNumber: "15", Next1: intPtr(5)},
+	}
+	cur := []ServiceTiming{
+		{ServiceNumber: "10", Next1: intPtr(3)},
+		{ServiceNumber: "21", Next1: intPtr(1)},
+	}
+	upd := diffServiceTimings("1", prev, cur)
+	if len(upd.Updated) != 1 || upd.Updated[0].ServiceNumber != "21" {
+		t.Errorf("expected 21 updated, got %+v", upd.Updated)
+	}
+	if len(upd.Removed) != 1 || upd.Removed[0] != "15" {
+		t.Errorf("expected 15 removed, got %+v", upd.Removed)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// defaultChangesWindow is how far back /api/v1/changes looks without since.
+const defaultChangesWindow = 30 * 24 * time.Hour
+
+// SyncLog serves the LTA sync history: /api/v1/sync/runs and
+// /api/v1/changes.
+type SyncLog struct {
+	store *store.Store
+}
+
+func NewSyncLog(s *store.Store) *SyncLog {
+	return &SyncLog{store: s}
+}
+
+// Runs lists recent sync runs, newest first.
+func (h *SyncLog) Runs(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	runs, err := h.store.SyncRuns(limit)
+	if err != nil {
+		slog.Error("Error listing sync runs", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list sync runs"})
+		return
+	}
+	if runs == nil {
+		runs = []store.SyncRun{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// Changes lists what LTA changed since a time (RFC 3339 or YYYY-MM-DD,
+// default the last 30 days), optionally for one service.
+func (h *SyncLog) Changes(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-defaultChangesWindow)
+	if s := r.URL.Query().Get("since"); s != "" {
+		t, err := parseSince(s)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 time or YYYY-MM-DD date"})
+			return
+		}
+		since = t
+	}
+	serviceNo := r.URL.Query().Get("service")
+
+	changes, err := h.store.ChangesSince(since, serviceNo)
+	if err != nil {
+		slog.Error("Error listing sync changes", "since", since, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list changes"})
+		return
+	}
+	if changes == nil {
+		changes = []store.SyncChange{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// parseSince accepts a full timestamp or a date, read as midnight in
+// Singapore.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, singaporeTime)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+var syncLogDataset = testDataset{
+	runs: []store.SyncResult{{Stops: 2, Routes: 4, Changes: []store.Change{
+		{Kind: store.ChangeStopAdded, StopCode: "12345"},
+		{Kind: store.ChangeRouteStopRemoved, StopCode: "99999", ServiceNo: "14", Direction: 1},
+	}}},
+}
+
+func TestSyncLogRuns(t *testing.T) {
+	h := NewSyncLog(seededStore(t, syncLogDataset))
+	rec := httptest.NewRecorder()
+	h.Runs(rec, httptest.NewRequest("GET", "/api/v1/sync/runs", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var runs []store.SyncRun
+	if err := json.NewDecoder(rec.Body).Decode(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != store.SyncOK || runs[0].Changes != 2 {
+		t.Errorf("unexpected runs %+v", runs)
+	}
+}
+
+func TestSyncLogChanges(t *testing.T) {
+	h := NewSyncLog(seededStore(t, syncLogDataset))
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"", http.StatusOK, 2},
+		{"?service=14", http.StatusOK, 1},
+		{"?since=2000-01-01", http.StatusOK, 2},
+		{"?since=2999-01-01T00:00:00Z", http.StatusOK, 0},
+		{"?since=yesterday", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.Changes(rec, httptest.NewRequest("GET", "/api/v1/changes"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: expected %d, got %d", tt.query, tt.code, rec.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var changes []store.SyncChange
+		if err := json.NewDecoder(rec.Body).Decode(&changes); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != tt.count {
+			t.Errorf("%q: expected %d changes, got %+v", tt.query, tt.count, changes)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// Trips answers "which bus goes from stop A to stop B?" at
+// /api/v1/trips?from=CODE&to=CODE.
+type Trips struct {
+	store *store.Store
+	lta   LTAClient
+}
+
+func NewTrips(s *store.Store, client LTAClient) *Trips {
+	return &Trips{store: s, lta: client}
+}
+
+type TripsResponse struct {
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Trips []TripResult `json:"trips"`
+	// ArrivalsUnavailable is set when trips are listed without ETAs because
+	// live arrivals at the origin couldn't be fetched.
+	ArrivalsUnavailable bool `json:"arrivalsUnavailable,omitempty"`
+}
+
+// TripResult is a direct service from the origin to the destination.
+// Arrival holds the next buses at the origin, if any are due.
+type TripResult struct {
+	ServiceNo string         `json:"serviceNo"`
+	Direction int            `json:"direction"`
+	Stops     int            `json:"stops"`
+	Distance  float64        `json:"distanceKm"`
+	Arrival   *ServiceTiming `json:"arrival,omitempty"`
+}
+
+func (h *Trips) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" || to == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from and to are required"})
+		return
+	}
+	if from == to {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from and to must be different stops"})
+		return
+	}
+
+	for _, code := range []string{from, to} {
+		stop, err := h.store.GetStop(code)
+		if err != nil {
+			slog.Error("Failed to get stop", "code", code, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to find trips"})
+			return
+		}
+		if stop == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "stop not found: " + code})
+			return
+		}
+		if stop.RetiredAt != nil {
+			h.writeRetired(w, stop)
+			return
+		}
+	}
+
+	direct, err := h.store.FindDirectTrips(from, to)
+	if err != nil {
+		slog.Error("Failed to find direct trips", "from", from, "to", to, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to find trips"})
+		return
+	}
+
+	resp := TripsResponse{From: from, To: to, Trips: []TripResult{}}
+	for _, t := range direct {
+		resp.Trips = append(resp.Trips, TripResult{
+			ServiceNo: t.ServiceNo,
+			Direction: t.Direction,
+			Stops:     t.Stops,
+			Distance:  math.Round(t.Distance*10) / 10,
+		})
+	}
+
+	if len(resp.Trips) > 0 {
+		timings, err := h.arrivalsAt(r.Context(), from)
+		if err != nil {
+			slog.Warn("Trips: failed to fetch arrivals at origin", "code", from, "error", err)
+			resp.ArrivalsUnavailable = true
+		}
+		for i := range resp.Trips {
+			if st, ok := timings[resp.Trips[i].ServiceNo]; ok {
+				resp.Trips[i].Arrival = &st
+			}
+		}
+	}
+
+	sort.SliceStable(resp.Trips, func(i, j int) bool {
+		a, b := resp.Trips[i], resp.Trips[j]
+		if a.Stops != b.Stops {
+			return a.Stops < b.Stops
+		}
+		return serviceLess(a.ServiceNo, b.ServiceNo)
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// arrivalsAt returns the current timings at a stop keyed by service number.
+// writeRetired answers 410 for a stop LTA has retired, pointing to the
+// nearest stop still in service as the stop page does.
+func (h *Trips) writeRetired(w http.ResponseWriter, stop *store.Stop) {
+	body := map[string]any{"error": "stop no longer in service: " + stop.Code}
+	nearest, err := h.store.Nearby(stop.Latitude, stop.Longitude, 1)
+	if err != nil {
+		slog.Warn("Failed to find stop near retired stop", "code", stop.Code, "error", err)
+	} else if len(nearest) > 0 {
+		body["nearest"] = nearest[0]
+	}
+	writeJSON(w, http.StatusGone, body)
+}
+
+func (h *Trips) arrivalsAt(ctx context.Context, code string) (map[string]ServiceTiming, error) {
+	arrivals, err := h.lta.GetBusArrival(ctx, code, "")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	timings := make(map[string]ServiceTiming, len(arrivals.Services))
+	for _, svc := range arrivals.Services {
+		timings[svc.ServiceNumber] = newServiceTiming(svc, now)
+	}
+	return timings, nil
+}
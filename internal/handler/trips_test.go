@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+var tripsDataset = testDataset{
+	stops: []lta.BusStop{
+		{BusStopCode: "12345", RoadName: "Road A"},
+		{BusStopCode: "67890", RoadName: "Road B"},
+		{BusStopCode: "11111", RoadName: "Road C"},
+	},
+	routes: []lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "12345", Distance: 0},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "11111", Distance: 0.7},
+		{ServiceNo: "10", Direction: 1, StopSequence: 3, BusStopCode: "67890", Distance: 2.3},
+		{ServiceNo: "196", Direction: 2, StopSequence: 4, BusStopCode: "12345", Distance: 3.1},
+		{ServiceNo: "196", Direction: 2, StopSequence: 5, BusStopCode: "67890", Distance: 4.2},
+	},
+}
+
+func TestTripsHandler(t *testing.T) {
+	h := NewTrips(seededStore(t, tripsDataset), &mockLTA{})
+
+	req := httptest.NewRequest("GET", "/api/v1/trips?from=12345&to=67890", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp TripsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(resp.Trips) != 2 {
+		t.Fatalf("expected 2 trips, got %+v", resp.Trips)
+	}
+
+	// 196 is one stop away, so it sorts before 10.
+	first, second := resp.Trips[0], resp.Trips[1]
+	if first.ServiceNo != "196" || first.Stops != 1 || first.Distance != 1.1 {
+		t.Errorf("unexpected first trip: %+v", first)
+	}
+	if second.ServiceNo != "10" || second.Stops != 2 || second.Distance != 2.3 {
+		t.Errorf("unexpected second trip: %+v", second)
+	}
+	if first.Arrival == nil || first.Arrival.Operator != "SMRT" {
+		t.Errorf("expected ETAs at origin for 196, got %+v", first.Arrival)
+	}
+}
+
+func TestTripsHandlerErrors(t *testing.T) {
+	s := seededStore(t, tripsDataset)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing to", "?from=12345", http.StatusBadRequest},
+		{"same stop", "?from=12345&to=12345", http.StatusBadRequest},
+		{"unknown stop", "?from=12345&to=99999", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewTrips(s, &mockLTA{})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/trips"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	t.Run("arrivals unavailable", func(t *testing.T) {
+		h := NewTrips(s, &arrivalsMockLTA{err: lta.ErrCircuitOpen})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/trips?from=12345&to=67890", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var resp TripsResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if !resp.ArrivalsUnavailable || len(resp.Trips) != 2 {
+			t.Errorf("expected trips without ETAs, got %+v", resp)
+		}
+	})
+}
+
+func TestTripsHandlerRetiredStop(t *testing.T) {
+	s := seededStore(t, tripsDataset)
+	// LTA drops 11111.
+	seedStore(t, s, testDataset{
+		stops: []lta.BusStop{
+			{BusStopCode: "12345", RoadName: "Road A"},
+			{BusStopCode: "67890", RoadName: "Road B", Latitude: 1.3, Longitude: 103.8},
+		},
+	})
+	h := NewTrips(s, &mockLTA{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/trips?from=12345&to=11111", nil))
+	if rec.Code != http.StatusGone {
+		t.Fatalf("expected 410, got %d", rec.Code)
+	}
+	var resp struct {
+		Nearest *store.StopWithDistance `json:"nearest"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Nearest == nil || resp.Nearest.Code != "12345" {
+		t.Errorf("expected the nearest active stop, got %+v", resp.Nearest)
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// upstreamStatus maps an error from the LTA client to the status code and
+// message we return to our own clients. fallback is used for errors that
+// aren't upstream-specific.
+func upstreamStatus(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, lta.ErrCircuitOpen):
+		return http.StatusServiceUnavailable, "Arrival data is temporarily unavailable"
+	case errors.Is(err, lta.ErrRateLimited):
+		return http.StatusServiceUnavailable, "Arrival data is temporarily rate limited"
+	case errors.Is(err, lta.ErrUnauthorized):
+		return http.StatusBadGateway, "Arrival data provider rejected our credentials"
+	case errors.Is(err, lta.ErrUpstream):
+		return http.StatusBadGateway, "Arrival data provider returned an error"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Arrival data provider timed out"
+	}
+	return http.StatusInternalServerError, fallback
+}
+
+// writeUpstreamError writes a JSON error for err, passing DataMall's
+// Retry-After hint through when it was rate limited.
+func writeUpstreamError(w http.ResponseWriter, err error, fallback string) {
+	var rle *lta.RateLimitError
+	if errors.As(err, &rle) && rle.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(rle.RetryAfter.Seconds())))
+	}
+	status, msg := upstreamStatus(err, fallback)
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func TestStopDetailUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantRetry  string
+	}{
+		{"circuit open", lta.ErrCircuitOpen, http.StatusServiceUnavailable, ""},
+		{"unauthorized", lta.ErrUnauthorized, http.StatusBadGateway, ""},
+		{"rate limited", &lta.RateLimitError{RetryAfter: 30 * time.Second}, http.StatusServiceUnavailable, "30"},
+		{"upstream 500", &lta.UpstreamError{StatusCode: 500, Body: "oops"}, http.StatusBadGateway, ""},
+		{"wrapped timeout", fmt.Errorf("fetch: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, ""},
+		{"other", errors.New("boom"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewStopDetail(&arrivalsMockLTA{err: tt.err}, testStore(t))
+			req := httptest.NewRequest("GET", "/api/v1/stops/12345/arrivals", nil)
+			req.SetPathValue("code", "12345")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("expected Retry-After %q, got %q", tt.wantRetry, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package lta
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling DataMall while the arrival
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("lta: circuit open")
+
+// BreakerState is the state of the arrival circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// BreakerStatus is a snapshot of the breaker for health reporting.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
+
+// WithBreaker sets how many consecutive upstream failures open the arrival
+// circuit breaker, and how long it stays open before a half-open probe.
+func WithBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.breaker.threshold = threshold
+		c.breaker.cooldown = cooldown
+	}
+}
+
+// breaker stops calling DataMall after repeated failures. After cooldown it
+// lets a single probe through; success closes it, failure re-opens it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker() *breaker {
+	return &breaker{
+		threshold: defaultBreakerThreshold,
+		cooldown:  defaultBreakerCooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether a call may proceed. In half-open state only one
+// probe is allowed at a time.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+	if err == nil || !breakerFailure(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := BreakerStatus{State: b.state.String(), ConsecutiveFailures: b.failures}
+	if b.state != BreakerClosed {
+		st.OpenedAt = new(b.openedAt)
+	}
+	return st
+}
+
+// breakerFailure reports whether err says DataMall itself is unhealthy.
+// Callers cancelling, bad credentials and bad requests don't count.
+func breakerFailure(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return retryable(err)
+}
+
+// BreakerStatus reports the state of the arrival circuit breaker.
+func (c *Client) BreakerStatus() BreakerStatus {
+	return c.breaker.status()
+}
@@ -0,0 +1,106 @@
+package lta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	now := time.Date(2024, 10, 12, 14, 0, 0, 0, time.UTC)
+	b := newBreaker()
+	b.threshold = 3
+	b.cooldown = 30 * time.Second
+	b.now = func() time.Time { return now }
+
+	transient := &UpstreamError{StatusCode: 503}
+	for range 3 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("expected closed breaker to allow, got %v", err)
+		}
+		b.record(transient)
+	}
+	if st := b.status(); st.State != "open" || st.OpenedAt == nil {
+		t.Fatalf("expected open breaker, got %+v", st)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// After cooldown one probe is let through; concurrent calls still fail fast.
+	now = now.Add(31 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected half-open probe, got %v", err)
+	}
+	if st := b.status(); st.State != "half-open" {
+		t.Errorf("expected half-open, got %s", st.State)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected second concurrent probe to be rejected, got %v", err)
+	}
+
+	// A failed probe re-opens the breaker.
+	b.record(transient)
+	if st := b.status(); st.State != "open" {
+		t.Errorf("expected re-opened breaker, got %s", st.State)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(31 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.record(nil)
+	if st := b.status(); st.State != "closed" || st.ConsecutiveFailures != 0 {
+		t.Errorf("expected closed breaker, got %+v", st)
+	}
+}
+
+func TestBreakerIgnoresNonUpstreamFailures(t *testing.T) {
+	b := newBreaker()
+	b.threshold = 1
+	for _, err := range []error{ErrUnauthorized, &UpstreamError{StatusCode: 404}, context.Canceled} {
+		b.allow()
+		b.record(err)
+	}
+	if st := b.status(); st.State != "closed" {
+		t.Errorf("expected closed breaker, got %s", st.State)
+	}
+
+	b.allow()
+	b.record(context.DeadlineExceeded)
+	if st := b.status(); st.State != "open" {
+		t.Errorf("expected timeouts to open the breaker, got %s", st.State)
+	}
+}
+
+func TestClientFailsFastWhenBreakerOpen(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := New("k", server.URL, WithArrivalRetry(noRetry), WithBreaker(2, time.Hour))
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := client.GetBusArrival(ctx, "75009", ""); !errors.Is(err, ErrUpstream) {
+			t.Fatalf("expected ErrUpstream, got %v", err)
+		}
+	}
+	if _, err := client.GetBusArrival(ctx, "75009", ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", n)
+	}
+	if st := client.BreakerStatus(); st.State != "open" {
+		t.Errorf("expected open breaker in status, got %s", st.State)
+	}
+}
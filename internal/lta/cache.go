@@ -0,0 +1,182 @@
+package lta
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCacheSize       = 5000
+	defaultJanitorInterval = time.Minute
+)
+
+// Cache stores arrival responses keyed by "busStopCode-serviceNumber".
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the entry for key if it is still retained. Entries past
+	// their TTL may be returned; callers check ExpiresAt themselves.
+	Get(key string) (*CacheEntry, bool)
+	// Peek is Get without side effects: it counts neither a hit nor a miss
+	// and leaves the entry's recency alone.
+	Peek(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	// Prune drops entries that are past retention and reports how many.
+	Prune() int
+	Stats() CacheStats
+}
+
+// CacheStats is a point-in-time view of cache effectiveness.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+}
+
+// memoryCache is an in-process LRU cache. Entries are kept for retain past
+// their ExpiresAt (so stale data can still be served) and are evicted
+// least-recently-used first once capacity is reached.
+type memoryCache struct {
+	capacity int
+	retain   time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type memoryItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+func newMemoryCache(capacity int, retain time.Duration) *memoryCache {
+	if capacity <= 0 {
+		capacity = defaultCacheSize
+	}
+	return &memoryCache{
+		capacity: capacity,
+		retain:   retain,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		m.misses.Add(1)
+		return nil, false
+	}
+	item := el.Value.(*memoryItem)
+	if m.expired(item.entry, time.Now()) {
+		m.remove(el)
+		m.misses.Add(1)
+		return nil, false
+	}
+	m.ll.MoveToFront(el)
+	m.hits.Add(1)
+	return item.entry, true
+}
+
+func (m *memoryCache) Peek(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*memoryItem)
+	if m.expired(item.entry, time.Now()) {
+		return nil, false
+	}
+	return item.entry, true
+}
+
+func (m *memoryCache) Set(key string, entry *CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		el.Value.(*memoryItem).entry = entry
+		m.ll.MoveToFront(el)
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memoryItem{key: key, entry: entry})
+	for m.ll.Len() > m.capacity {
+		m.remove(m.ll.Back())
+	}
+}
+
+func (m *memoryCache) Prune() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var n int
+	for el := m.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if m.expired(el.Value.(*memoryItem).entry, now) {
+			m.remove(el)
+			n++
+		}
+		el = prev
+	}
+	return n
+}
+
+func (m *memoryCache) Stats() CacheStats {
+	m.mu.Lock()
+	size := m.ll.Len()
+	m.mu.Unlock()
+	return CacheStats{
+		Hits:      m.hits.Load(),
+		Misses:    m.misses.Load(),
+		Evictions: m.evictions.Load(),
+		Size:      size,
+		Capacity:  m.capacity,
+	}
+}
+
+func (m *memoryCache) expired(e *CacheEntry, now time.Time) bool {
+	return now.After(e.ExpiresAt.Add(m.retain))
+}
+
+// remove must be called with m.mu held.
+func (m *memoryCache) remove(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*memoryItem).key)
+	m.evictions.Add(1)
+}
+
+// RunJanitor periodically prunes expired cache entries until ctx is done.
+func (c *Client) RunJanitor(ctx context.Context) {
+	ticker := time.NewTicker(defaultJanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.cache.Prune()
+		}
+	}
+}
+
+// CacheStats reports hit, miss, eviction and size counters for the arrival
+// cache.
+func (c *Client) CacheStats() CacheStats {
+	return c.cache.Stats()
+}
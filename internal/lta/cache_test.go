@@ -0,0 +1,116 @@
+package lta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func freshEntry() *CacheEntry {
+	now := time.Now()
+	return &CacheEntry{Data: &BusArrival{}, FetchedAt: now, ExpiresAt: now.Add(time.Minute)}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newMemoryCache(2, 0)
+	c.Set("a", freshEntry())
+	c.Set("b", freshEntry())
+
+	// Touch "a" so "b" becomes the least recently used.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.Set("c", freshEntry())
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to survive")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+
+	st := c.Stats()
+	if st.Size != 2 || st.Capacity != 2 {
+		t.Errorf("expected size 2 / capacity 2, got %+v", st)
+	}
+	if st.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", st.Evictions)
+	}
+	if st.Hits != 3 || st.Misses != 1 {
+		t.Errorf("expected 3 hits / 1 miss, got %+v", st)
+	}
+}
+
+func TestMemoryCacheRetainsForGraceThenPrunes(t *testing.T) {
+	c := newMemoryCache(10, time.Minute)
+
+	c.Set("stale", &CacheEntry{Data: &BusArrival{}, ExpiresAt: time.Now().Add(-30 * time.Second)})
+	c.Set("gone", &CacheEntry{Data: &BusArrival{}, ExpiresAt: time.Now().Add(-2 * time.Minute)})
+	c.Set("fresh", freshEntry())
+
+	if n := c.Prune(); n != 1 {
+		t.Errorf("expected 1 pruned entry, got %d", n)
+	}
+	if _, ok := c.Get("stale"); !ok {
+		t.Error("expected entry within grace to be retained")
+	}
+	if _, ok := c.Get("gone"); ok {
+		t.Error("expected entry past grace to be pruned")
+	}
+	if st := c.Stats(); st.Size != 2 {
+		t.Errorf("expected size 2, got %d", st.Size)
+	}
+}
+
+func TestMemoryCachePeekHasNoSideEffects(t *testing.T) {
+	c := newMemoryCache(2, 0)
+	c.Set("a", freshEntry())
+	c.Set("b", freshEntry())
+
+	// Peeking at "a" must not save it from eviction.
+	if _, ok := c.Peek("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	if _, ok := c.Peek("missing"); ok {
+		t.Error("expected no entry for missing")
+	}
+	c.Set("c", freshEntry())
+
+	if _, ok := c.Peek("a"); ok {
+		t.Error("expected a to be evicted")
+	}
+	if st := c.Stats(); st.Hits != 0 || st.Misses != 0 {
+		t.Errorf("expected peeks to leave hits and misses alone, got %+v", st)
+	}
+}
+
+func TestClientUsesCustomCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	cache := newMemoryCache(1, 0)
+	client := New("test-api-key", server.URL, WithCache(cache))
+
+	ctx := context.Background()
+	if _, err := client.GetBusArrival(ctx, "75009", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBusArrival(ctx, "75009", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBusArrival(ctx, "12345", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	st := client.CacheStats()
+	if st.Hits != 1 || st.Misses != 2 || st.Evictions != 1 || st.Size != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
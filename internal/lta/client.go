@@ -3,144 +3,242 @@ package lta
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
-	"sync"
 	"time"
 )
 
-const defaultHost = "https://datamall2.mytransport.sg"
+const (
+	defaultHost = "https://datamall2.mytransport.sg"
+
+	arrivalTTL        = 10 * time.Second
+	defaultStaleGrace = 2 * time.Minute
+	// staleAfter is how old revalidated data may get before it is marked
+	// stale even though no refresh has failed yet. It sits well above
+	// arrivalTTL so routine background refreshes never show as stale.
+	staleAfter = time.Minute
+)
 
 type Client struct {
-	apiKey string
-	host   string
-	cache  map[string]*CacheEntry
-	mu     sync.RWMutex
+	apiKey     string
+	host       string
+	staleGrace time.Duration
+	cacheSize  int
+	cache      Cache
+	flight     flightGroup[*BusArrival]
+
+	syncRetry    RetryPolicy
+	arrivalRetry RetryPolicy
+	breaker      *breaker
+	limiter      *limiter
 }
 
 type CacheEntry struct {
 	Data      *BusArrival
+	FetchedAt time.Time
 	ExpiresAt time.Time
+	// RefreshFailed is set once a background refresh of the expired entry
+	// has failed.
+	RefreshFailed bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithStaleGrace sets how long an expired arrival entry is still served
+// while it is refreshed in the background. Zero disables serving expired
+// data.
+func WithStaleGrace(d time.Duration) Option {
+	return func(c *Client) { c.staleGrace = d }
+}
+
+// WithCacheSize bounds the number of arrival entries kept in memory.
+func WithCacheSize(n int) Option {
+	return func(c *Client) { c.cacheSize = n }
+}
+
+// WithCache replaces the default in-memory arrival cache, e.g. with a
+// shared backend.
+func WithCache(cache Cache) Option {
+	return func(c *Client) { c.cache = cache }
 }
 
-func New(apiKey, host string) *Client {
+func New(apiKey, host string, opts ...Option) *Client {
 	if host == "" {
 		host = defaultHost
 	}
-	return &Client{
-		apiKey: apiKey,
-		host:   host,
-		cache:  make(map[string]*CacheEntry),
+	c := &Client{
+		apiKey:     apiKey,
+		host:       host,
+		staleGrace: defaultStaleGrace,
+		cacheSize:  defaultCacheSize,
+
+		syncRetry:    SyncRetryPolicy,
+		arrivalRetry: ArrivalRetryPolicy,
+		breaker:      newBreaker(),
+		limiter:      newLimiter(defaultRateLimit, defaultRateBurst),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	if c.cache == nil {
+		c.cache = newMemoryCache(c.cacheSize, c.staleGrace)
+	}
+	return c
 }
 
+// GetBusArrival returns arrivals for a stop, optionally filtered to one
+// service. Results are cached briefly; once an entry expires it is still
+// returned for the stale grace window while a background request refreshes
+// it. Stale is only set when that refresh has failed or the data is older
+// than staleAfter. While the circuit breaker is open, misses fail fast with
+// ErrCircuitOpen.
 func (c *Client) GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*BusArrival, error) {
 	cacheKey := fmt.Sprintf("%s-%s", busStopCode, serviceNumber)
-	c.mu.RLock()
-	if entry, found := c.cache[cacheKey]; found && time.Now().Before(entry.ExpiresAt) {
-		c.mu.RUnlock()
+	now := time.Now()
+	entry, found := c.cache.Get(cacheKey)
+
+	if found && now.Before(entry.ExpiresAt) {
 		return entry.Data, nil
 	}
-	c.mu.RUnlock()
 
-	url := c.host + "/ltaodataservice/v3/BusArrival"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+	fetch := func(ctx context.Context) (*BusArrival, error) {
+		if err := c.breaker.allow(); err != nil {
+			return nil, err
+		}
+		res, err := c.fetchBusArrival(ctx, cacheKey, busStopCode, serviceNumber)
+		c.breaker.record(err)
+		return res, err
+	}
+
+	if found && now.Before(entry.ExpiresAt.Add(c.staleGrace)) {
+		go func() {
+			if _, err := c.flight.do(context.Background(), cacheKey, fetch); err != nil {
+				if !errors.Is(err, ErrCircuitOpen) {
+					slog.Warn("Background arrival refresh failed", "key", cacheKey, "error", err)
+				}
+				c.markRefreshFailed(cacheKey, entry)
+			}
+		}()
+		if !entry.RefreshFailed && now.Sub(entry.FetchedAt) <= staleAfter {
+			return entry.Data, nil
+		}
+		stale := *entry.Data
+		stale.Stale = true
+		return &stale, nil
+	}
+
+	// Concurrent misses for the same key share one upstream request.
+	return c.flight.do(ctx, cacheKey, fetch)
+}
+
+// markRefreshFailed flags entry as failing to refresh, unless the cache
+// has been given a newer entry for key in the meantime.
+func (c *Client) markRefreshFailed(key string, entry *CacheEntry) {
+	cur, ok := c.cache.Peek(key)
+	if !ok || !cur.FetchedAt.Equal(entry.FetchedAt) || cur.RefreshFailed {
+		return
 	}
+	failed := *cur
+	failed.RefreshFailed = true
+	c.cache.Set(key, &failed)
+}
 
-	q := req.URL.Query()
+func (c *Client) fetchBusArrival(ctx context.Context, cacheKey, busStopCode, serviceNumber string) (*BusArrival, error) {
+	q := url.Values{}
 	q.Add("BusStopCode", busStopCode)
 	q.Add("ServiceNo", serviceNumber)
-	req.URL.RawQuery = q.Encode()
-	req.Header.Add("AccountKey", c.apiKey)
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
 
 	var busArrival BusArrival
-	if err := json.Unmarshal(body, &busArrival); err != nil {
+	if err := c.get(ctx, c.arrivalRetry, PriorityInteractive, "/ltaodataservice/v3/BusArrival", q, &busArrival); err != nil {
 		return nil, err
 	}
 
-	c.mu.Lock()
-	c.cache[cacheKey] = &CacheEntry{
+	now := time.Now()
+	busArrival.AsOf = now
+	c.cache.Set(cacheKey, &CacheEntry{
 		Data:      &busArrival,
-		ExpiresAt: time.Now().Add(10 * time.Second),
-	}
-	c.mu.Unlock()
+		FetchedAt: now,
+		ExpiresAt: now.Add(arrivalTTL),
+	})
 
 	return &busArrival, nil
 }
 
 func (c *Client) GetBusStops(ctx context.Context, skip int) (*Response[BusStop], error) {
-	url := c.host + "/ltaodataservice/BusStops"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	q := req.URL.Query()
-	q.Add("$skip", strconv.Itoa(skip))
-	req.URL.RawQuery = q.Encode()
-	req.Header.Add("AccountKey", c.apiKey)
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
+	var busStops Response[BusStop]
+	if err := c.get(ctx, c.syncRetry, PriorityBackground, "/ltaodataservice/BusStops", skipQuery(skip), &busStops); err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
+	return &busStops, nil
+}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
+func (c *Client) GetBusRoutes(ctx context.Context, skip int) (*Response[BusRoute], error) {
+	var routes Response[BusRoute]
+	if err := c.get(ctx, c.syncRetry, PriorityBackground, "/ltaodataservice/BusRoutes", skipQuery(skip), &routes); err != nil {
 		return nil, err
 	}
+	return &routes, nil
+}
 
-	var busStops Response[BusStop]
-	if err := json.Unmarshal(body, &busStops); err != nil {
+func (c *Client) GetBusServices(ctx context.Context, skip int) (*Response[BusService], error) {
+	var services Response[BusService]
+	if err := c.get(ctx, c.syncRetry, PriorityBackground, "/ltaodataservice/BusServices", skipQuery(skip), &services); err != nil {
 		return nil, err
 	}
+	return &services, nil
+}
 
-	return &busStops, nil
+// get performs an authenticated GET against DataMall and decodes the JSON
+// response into v, retrying transient failures according to policy. Every
+// attempt waits on the shared rate limiter in the lane given by ctx, or
+// prio if ctx doesn't set one. Non-2xx responses are returned as typed
+// errors.
+func (c *Client) get(ctx context.Context, policy RetryPolicy, prio Priority, path string, q url.Values, v any) error {
+	prio = priorityFrom(ctx, prio)
+	return policy.do(ctx, func() error {
+		if err := c.limiter.wait(ctx, prio); err != nil {
+			return err
+		}
+		return c.getOnce(ctx, path, q, v)
+	})
 }
 
-func (c *Client) GetBusRoutes(ctx context.Context, skip int) (*Response[BusRoute], error) {
-	url := c.host + "/ltaodataservice/BusRoutes"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func (c *Client) getOnce(ctx context.Context, path string, q url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.host+path, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	q := req.URL.Query()
-	q.Add("$skip", strconv.Itoa(skip))
 	req.URL.RawQuery = q.Encode()
 	req.Header.Add("AccountKey", c.apiKey)
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer res.Body.Close()
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := checkResponse(res, body); err != nil {
+		return err
 	}
 
-	var routes Response[BusRoute]
-	if err := json.Unmarshal(body, &routes); err != nil {
-		return nil, err
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w %s response: %w", errDecode, path, err)
 	}
+	return nil
+}
 
-	return &routes, nil
+func skipQuery(skip int) url.Values {
+	q := url.Values{}
+	q.Add("$skip", strconv.Itoa(skip))
+	return q
 }
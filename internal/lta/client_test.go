@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -110,3 +112,341 @@ func TestGetBusArrival(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBusArrivalCoalescesConcurrentCalls(t *testing.T) {
+	const callers = 20
+
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.GetBusArrival(context.Background(), "75009", "")
+			if err == nil && res.BusStopCode != "75009" {
+				t.Errorf("expected 75009, got %s", res.BusStopCode)
+			}
+			errs <- err
+		}()
+	}
+
+	// Hold the upstream response until every caller has joined the flight.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client.flight.mu.Lock()
+		c := client.flight.calls["75009-"]
+		joined := c != nil && c.dups == callers-1
+		client.flight.mu.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for callers to join")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected exactly 1 upstream call, got %d", n)
+	}
+}
+
+func TestGetBusArrivalCallerCancelDoesNotFailOthers(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := client.GetBusArrival(ctx, "75009", "10")
+		first <- err
+	}()
+
+	for {
+		client.flight.mu.Lock()
+		started := client.flight.calls["75009-10"] != nil
+		client.flight.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := client.GetBusArrival(context.Background(), "75009", "10")
+		second <- err
+	}()
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("expected first caller to be canceled, got %v", err)
+	}
+
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("expected second caller to succeed, got %v", err)
+	}
+}
+
+func TestGetBusArrivalServesStaleWhileRevalidating(t *testing.T) {
+	var hits atomic.Int32
+	fail := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL, WithStaleGrace(time.Minute), WithArrivalRetry(noRetry))
+	ctx := context.Background()
+
+	first, err := client.GetBusArrival(ctx, "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Stale || first.AsOf.IsZero() {
+		t.Errorf("expected fresh data with AsOf set, got stale=%v asOf=%v", first.Stale, first.AsOf)
+	}
+
+	// Expire the entry but keep it within the grace window, and make the
+	// upstream fail: once the background refresh fails, the cached copy
+	// should still be served, marked stale.
+	fail.Store(true)
+	expireEntry(t, client, "75009-")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stale, err := client.GetBusArrival(ctx, "75009", "")
+		if err != nil {
+			t.Fatalf("expected stale data, got error: %v", err)
+		}
+		if stale.Stale {
+			if !stale.AsOf.Equal(first.AsOf) {
+				t.Errorf("expected stale data as of %v, got %v", first.AsOf, stale.AsOf)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failed refresh never marked the entry stale")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if first.Stale {
+		t.Error("marking a response stale must not mutate the cached entry")
+	}
+
+	// Once upstream recovers, the background refresh replaces the entry.
+	fail.Store(false)
+	expireEntry(t, client, "75009-")
+	if _, err := client.GetBusArrival(ctx, "75009", ""); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		fresh, err := client.GetBusArrival(ctx, "75009", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fresh.Stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh never replaced the stale entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetBusArrivalRevalidatesWithoutStale(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL, WithStaleGrace(time.Minute), WithArrivalRetry(noRetry))
+	ctx := context.Background()
+
+	first, err := client.GetBusArrival(ctx, "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A healthy upstream: the expired entry is served as is while it is
+	// refreshed, not marked stale.
+	expireEntry(t, client, "75009-")
+	res, err := client.GetBusArrival(ctx, "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stale || !res.AsOf.Equal(first.AsOf) {
+		t.Errorf("expected the cached data without Stale, got stale=%v asOf=%v", res.Stale, res.AsOf)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired entry was never refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	res, err = client.GetBusArrival(ctx, "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stale {
+		t.Error("expected refreshed data not to be stale")
+	}
+}
+
+func TestGetBusArrivalMarksOldDataStale(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL, WithStaleGrace(5*time.Minute))
+	fetched := time.Now().Add(-2 * staleAfter)
+	client.cache.Set("75009-", &CacheEntry{
+		Data:      &BusArrival{BusStopCode: "75009", AsOf: fetched},
+		FetchedAt: fetched,
+		ExpiresAt: fetched.Add(arrivalTTL),
+	})
+
+	res, err := client.GetBusArrival(context.Background(), "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Stale {
+		t.Error("expected data older than staleAfter to be marked stale")
+	}
+}
+
+func TestGetBusArrivalPastGraceFetchesSynchronously(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(busArrivalResponse))
+	}))
+	defer server.Close()
+
+	client := New("test-api-key", server.URL, WithStaleGrace(time.Minute))
+	client.cache.Set("75009-", &CacheEntry{
+		Data:      &BusArrival{BusStopCode: "old"},
+		ExpiresAt: time.Now().Add(-2 * time.Minute),
+	})
+
+	res, err := client.GetBusArrival(context.Background(), "75009", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stale || res.BusStopCode != "75009" {
+		t.Errorf("expected fresh upstream data, got %+v", res)
+	}
+}
+
+// expireEntry replaces the cached entry for key with a copy whose TTL has
+// just passed.
+func TestMarkRefreshFailedKeepsCacheStats(t *testing.T) {
+	client := New("test-api-key", "http://unused")
+	entry := freshEntry()
+	client.cache.Set("75009-", entry)
+	before := client.CacheStats()
+
+	client.markRefreshFailed("75009-", entry)
+
+	if st := client.CacheStats(); st != before {
+		t.Errorf("expected a failed refresh to leave stats at %+v, got %+v", before, st)
+	}
+	if cur, ok := client.cache.Peek("75009-"); !ok || !cur.RefreshFailed {
+		t.Error("expected the entry to be marked as failing to refresh")
+	}
+}
+
+func expireEntry(t *testing.T, c *Client, key string) {
+	t.Helper()
+	e, ok := c.cache.Get(key)
+	if !ok {
+		t.Fatalf("no cache entry for %s", key)
+	}
+	expired := *e
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	c.cache.Set(key, &expired)
+}
+
+const busServicesResponse = `{
+    "odata.metadata": "https://datamall2.mytransport.sg/ltaodataservice/$metadata#BusServices",
+    "value": [
+        {
+            "ServiceNo": "118",
+            "Operator": "GAS",
+            "Direction": 1,
+            "Category": "TRUNK",
+            "OriginCode": "65009",
+            "DestinationCode": "97009",
+            "AM_Peak_Freq": "05-08",
+            "AM_Offpeak_Freq": "10-16",
+            "PM_Peak_Freq": "08-12",
+            "PM_Offpeak_Freq": "10-13",
+            "LoopDesc": ""
+        }
+    ]
+}`
+
+func TestGetBusServices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ltaodataservice/BusServices" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("$skip") != "500" {
+			t.Errorf("expected $skip=500, got %q", r.URL.Query().Get("$skip"))
+		}
+		w.Write([]byte(busServicesResponse))
+	}))
+	defer server.Close()
+
+	res, err := New("test-api-key", server.URL).GetBusServices(context.Background(), 500)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(res.Value) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(res.Value))
+	}
+	svc := res.Value[0]
+	if svc.ServiceNo != "118" || svc.Operator != "GAS" || svc.Category != "TRUNK" {
+		t.Errorf("unexpected service: %+v", svc)
+	}
+	if svc.AMPeakFreq != "05-08" || svc.PMOffpeakFreq != "10-13" {
+		t.Errorf("unexpected frequencies: %+v", svc)
+	}
+}
@@ -0,0 +1,87 @@
+package lta
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxErrorBody caps how much of an error response body is kept in an
+// UpstreamError.
+const maxErrorBody = 256
+
+var (
+	// ErrUnauthorized means DataMall rejected the AccountKey.
+	ErrUnauthorized = errors.New("lta: unauthorized")
+	// ErrRateLimited means DataMall throttled the request. The concrete
+	// error is a *RateLimitError carrying the Retry-After hint.
+	ErrRateLimited = errors.New("lta: rate limited")
+	// ErrUpstream means DataMall returned an unexpected status. The concrete
+	// error is an *UpstreamError.
+	ErrUpstream = errors.New("lta: upstream error")
+
+	// errDecode wraps JSON decoding failures, which retrying won't fix.
+	errDecode = errors.New("lta: decoding")
+)
+
+// RateLimitError is returned for HTTP 429 responses.
+type RateLimitError struct {
+	// RetryAfter is how long DataMall asked us to wait; zero if not given.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("lta: rate limited, retry after %s", e.RetryAfter)
+	}
+	return "lta: rate limited"
+}
+
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// UpstreamError is returned for non-2xx responses other than 401/403/429.
+type UpstreamError struct {
+	StatusCode int
+	// Body is the start of the response body, for diagnostics.
+	Body string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("lta: upstream returned %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *UpstreamError) Is(target error) bool { return target == ErrUpstream }
+
+// checkResponse maps a non-2xx DataMall response to a typed error. body is
+// the already-read response body.
+func checkResponse(res *http.Response, body []byte) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	switch res.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now())}
+	}
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &UpstreamError{StatusCode: res.StatusCode, Body: string(body)}
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
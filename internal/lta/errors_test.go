@@ -0,0 +1,107 @@
+package lta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamStatusErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		body   string
+		want   error
+	}{
+		{"unauthorized", http.StatusUnauthorized, nil, `{"fault":"bad key"}`, ErrUnauthorized},
+		{"forbidden", http.StatusForbidden, nil, "", ErrUnauthorized},
+		{"rate limited", http.StatusTooManyRequests, map[string]string{"Retry-After": "30"}, "", ErrRateLimited},
+		{"server error", http.StatusInternalServerError, nil, strings.Repeat("x", 1000), ErrUpstream},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := New("test-api-key", server.URL, WithSyncRetry(noRetry), WithArrivalRetry(noRetry))
+			ctx := context.Background()
+
+			_, arrivalErr := client.GetBusArrival(ctx, "75009", "")
+			_, stopsErr := client.GetBusStops(ctx, 0)
+			_, routesErr := client.GetBusRoutes(ctx, 0)
+			for _, err := range []error{arrivalErr, stopsErr, routesErr} {
+				if !errors.Is(err, tt.want) {
+					t.Errorf("expected %v, got %v", tt.want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitErrorRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := New("k", server.URL, WithSyncRetry(noRetry)).GetBusStops(context.Background(), 0)
+	var rle *RateLimitError
+	if !errors.As(err, &rle) {
+		t.Fatalf("expected *RateLimitError, got %T: %v", err, err)
+	}
+	if rle.RetryAfter != 30*time.Second {
+		t.Errorf("expected 30s, got %s", rle.RetryAfter)
+	}
+}
+
+func TestUpstreamErrorTruncatesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	_, err := New("k", server.URL, WithSyncRetry(noRetry)).GetBusRoutes(context.Background(), 0)
+	var ue *UpstreamError
+	if !errors.As(err, &ue) {
+		t.Fatalf("expected *UpstreamError, got %T: %v", err, err)
+	}
+	if ue.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected 502, got %d", ue.StatusCode)
+	}
+	if len(ue.Body) != maxErrorBody {
+		t.Errorf("expected body truncated to %d, got %d", maxErrorBody, len(ue.Body))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 10, 12, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"garbage", 0},
+		{now.Add(45 * time.Second).Format(http.TimeFormat), 45 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
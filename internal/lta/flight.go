@@ -0,0 +1,65 @@
+package lta
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// upstreamTimeout bounds a shared upstream request. The request is detached
+// from any single caller's context so one caller giving up doesn't fail the
+// others waiting on the same result.
+const upstreamTimeout = 10 * time.Second
+
+// flightGroup coalesces concurrent calls for the same key into one execution
+// whose result is shared by every caller.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+	dups int
+}
+
+// do runs fn once per key at a time. Callers arriving while fn is in flight
+// wait for its result instead of starting their own. Each caller stops
+// waiting when its own ctx is done.
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.dups++
+	} else {
+		c = &flightCall[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(ctx, key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(ctx context.Context, key string, c *flightCall[T], fn func(context.Context) (T, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), upstreamTimeout)
+	defer cancel()
+
+	c.val, c.err = fn(ctx)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
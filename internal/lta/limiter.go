@@ -0,0 +1,143 @@
+package lta
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimit = 10 // requests per second
+	defaultRateBurst = 20
+	// backgroundReserve is the share of the bucket background calls may not
+	// touch, so interactive lookups always find tokens after a sync burst.
+	backgroundReserve = 0.25
+)
+
+// Priority selects the rate limiter lane for a DataMall call.
+type Priority int
+
+const (
+	// PriorityInteractive is for user-facing lookups. It is the default for
+	// arrivals and always goes ahead of background calls.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for sync traffic. It is the default for
+	// BusStops, BusRoutes and BusServices paging.
+	PriorityBackground
+)
+
+type priorityKey struct{}
+
+// WithPriority tags ctx so DataMall calls made with it use the given
+// rate limiter lane, e.g. the syncer tags its dataset sync so every call
+// it makes stays in the background lane.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context, def Priority) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return def
+}
+
+// WithRateLimit sets the shared outbound request rate and burst for all
+// DataMall calls. A rate of zero disables limiting.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) { c.limiter = newLimiter(perSecond, burst) }
+}
+
+// limiter is a token bucket with two lanes. Interactive calls may use every
+// token; background calls leave a reserve untouched and yield while any
+// interactive call is waiting.
+type limiter struct {
+	rate    float64
+	burst   float64
+	reserve float64
+
+	mu                 sync.Mutex
+	tokens             float64
+	last               time.Time
+	interactiveWaiting int
+}
+
+func newLimiter(perSecond float64, burst int) *limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	b := float64(burst)
+	return &limiter{
+		rate:    perSecond,
+		burst:   b,
+		reserve: math.Floor(b * backgroundReserve),
+		tokens:  b,
+		last:    time.Now(),
+	}
+}
+
+// wait blocks until a token is available for the given lane or ctx is done.
+func (l *limiter) wait(ctx context.Context, p Priority) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if p == PriorityInteractive {
+		l.interactiveWaiting++
+	}
+	l.mu.Unlock()
+	defer func() {
+		if p == PriorityInteractive {
+			l.mu.Lock()
+			l.interactiveWaiting--
+			l.mu.Unlock()
+		}
+	}()
+
+	for {
+		delay := l.take(p)
+		if delay == 0 {
+			return nil
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// take consumes a token if one is available to lane p and returns zero;
+// otherwise it returns how long to wait before trying again.
+func (l *limiter) take(p Priority) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	need := 1.0
+	if p == PriorityBackground {
+		if l.interactiveWaiting > 0 {
+			return l.tokenInterval()
+		}
+		need += l.reserve
+	}
+	if l.tokens >= need {
+		l.tokens--
+		return 0
+	}
+	return max(time.Duration((need-l.tokens)/l.rate*float64(time.Second)), time.Millisecond)
+}
+
+func (l *limiter) tokenInterval() time.Duration {
+	return max(time.Duration(float64(time.Second)/l.rate), time.Millisecond)
+}
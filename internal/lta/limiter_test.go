@@ -0,0 +1,103 @@
+package lta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterBackgroundLeavesReserve(t *testing.T) {
+	l := newLimiter(1, 4) // reserve of 1 token
+	ctx := context.Background()
+
+	for i := range 3 {
+		if d := l.take(PriorityBackground); d != 0 {
+			t.Fatalf("background call %d: expected token, got wait %s", i, d)
+		}
+	}
+	if d := l.take(PriorityBackground); d == 0 {
+		t.Error("expected background to be denied the reserved token")
+	}
+
+	start := time.Now()
+	if err := l.wait(ctx, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("expected interactive call to use the reserve without waiting")
+	}
+}
+
+func TestLimiterInteractiveGoesFirst(t *testing.T) {
+	l := newLimiter(50, 1)
+	ctx := context.Background()
+	if err := l.wait(ctx, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan Priority, 2)
+	go func() {
+		l.wait(ctx, PriorityBackground)
+		order <- PriorityBackground
+	}()
+	time.Sleep(5 * time.Millisecond)
+	go func() {
+		l.wait(ctx, PriorityInteractive)
+		order <- PriorityInteractive
+	}()
+
+	if first := <-order; first != PriorityInteractive {
+		t.Error("expected the interactive call to get the next token")
+	}
+	<-order
+}
+
+func TestLimiterRespectsContext(t *testing.T) {
+	l := newLimiter(0.001, 1)
+	l.take(PriorityInteractive)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, PriorityInteractive); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	var l *limiter = newLimiter(0, 0)
+	if l != nil {
+		t.Fatal("expected nil limiter for zero rate")
+	}
+	if err := l.wait(context.Background(), PriorityBackground); err != nil {
+		t.Errorf("expected disabled limiter to allow, got %v", err)
+	}
+}
+
+func TestClientRateLimitsOutboundCalls(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"value":[]}`))
+	}))
+	defer server.Close()
+
+	client := New("k", server.URL, WithRateLimit(20, 1))
+	ctx := WithPriority(context.Background(), PriorityInteractive)
+
+	start := time.Now()
+	for range 3 {
+		if _, err := client.GetBusStops(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One token up front, then two more at 20/s.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("expected calls to be spaced by the limiter, took %s", elapsed)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+}
@@ -0,0 +1,106 @@
+package lta
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how idempotent GETs to DataMall are retried. Delays
+// grow exponentially from BaseDelay, capped at MaxDelay, with full jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var (
+	// SyncRetryPolicy suits background paging, where finishing the run
+	// matters more than latency.
+	SyncRetryPolicy = RetryPolicy{MaxAttempts: 6, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	// ArrivalRetryPolicy suits user-facing lookups: one quick retry, then
+	// give up and let the caller fall back.
+	ArrivalRetryPolicy = RetryPolicy{MaxAttempts: 2, BaseDelay: 200 * time.Millisecond, MaxDelay: time.Second}
+)
+
+// WithSyncRetry sets the retry policy for BusStops, BusRoutes and
+// BusServices paging.
+func WithSyncRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.syncRetry = p }
+}
+
+// WithArrivalRetry sets the retry policy for BusArrival lookups.
+func WithArrivalRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.arrivalRetry = p }
+}
+
+// do calls fn until it succeeds, returns a non-retryable error, runs out of
+// attempts, or ctx is done.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt+1 >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+		wait, ok := p.delay(attempt, err)
+		if !ok {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the retry following attempt (0-based).
+// A Retry-After hint from DataMall takes precedence; if it's longer than the
+// policy allows, ok is false and the caller should stop retrying.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	var rle *RateLimitError
+	if errors.As(err, &rle) && rle.RetryAfter > 0 {
+		if rle.RetryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return rle.RetryAfter, true
+	}
+
+	backoff := p.BaseDelay << attempt
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	return rand.N(backoff + 1), true
+}
+
+// retryable reports whether err is a transient failure worth retrying:
+// rate limiting, 5xx/408 responses and network errors. Bad credentials,
+// other 4xx responses, decode errors and cancellation are final.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, errDecode) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var ue *UpstreamError
+	if errors.As(err, &ue) {
+		return ue.StatusCode >= 500 || ue.StatusCode == http.StatusRequestTimeout
+	}
+	return true
+}
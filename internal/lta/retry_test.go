@@ -0,0 +1,139 @@
+package lta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// noRetry disables retries so error-path tests don't wait on backoff.
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+var fastRetry = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryRecoversFromTransientFailures(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"value":[{"BusStopCode":"01012"}]}`))
+	}))
+	defer server.Close()
+
+	client := New("k", server.URL, WithSyncRetry(fastRetry))
+	res, err := client.GetBusStops(context.Background(), 5000)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if len(res.Value) != 1 {
+		t.Errorf("expected 1 stop, got %d", len(res.Value))
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := New("k", server.URL, WithSyncRetry(fastRetry))
+	_, err := client.GetBusRoutes(context.Background(), 0)
+	if !errors.Is(err, ErrUpstream) {
+		t.Errorf("expected ErrUpstream, got %v", err)
+	}
+	if n := hits.Load(); n != int32(fastRetry.MaxAttempts) {
+		t.Errorf("expected %d attempts, got %d", fastRetry.MaxAttempts, n)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := New("k", server.URL, WithSyncRetry(fastRetry))
+	if _, err := client.GetBusStops(context.Background(), 0); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected no retries for 401, got %d attempts", n)
+	}
+}
+
+func TestRetryStopsOnContextCancel(t *testing.T) {
+	var hits atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	slow := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	client := New("k", server.URL, WithSyncRetry(slow))
+	if _, err := client.GetBusStops(ctx, 0); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected 1 attempt before cancel, got %d", n)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	transient := &UpstreamError{StatusCode: 503}
+
+	for attempt := range 6 {
+		limit := min(p.BaseDelay<<attempt, p.MaxDelay)
+		for range 50 {
+			d, ok := p.delay(attempt, transient)
+			if !ok || d < 0 || d > limit {
+				t.Fatalf("attempt %d: delay %s outside [0, %s]", attempt, d, limit)
+			}
+		}
+	}
+
+	d, ok := p.delay(0, &RateLimitError{RetryAfter: 500 * time.Millisecond})
+	if !ok || d != 500*time.Millisecond {
+		t.Errorf("expected Retry-After to be honoured, got %s ok=%v", d, ok)
+	}
+	if _, ok := p.delay(0, &RateLimitError{RetryAfter: time.Minute}); ok {
+		t.Error("expected Retry-After beyond MaxDelay to stop retrying")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&UpstreamError{StatusCode: 502}, true},
+		{&UpstreamError{StatusCode: 408}, true},
+		{&UpstreamError{StatusCode: 404}, false},
+		{&RateLimitError{}, true},
+		{ErrUnauthorized, false},
+		{context.Canceled, false},
+		{errDecode, false},
+		{errors.New("connection reset"), true},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -30,6 +30,12 @@ func (st *SafeTime) UnmarshalJSON(data []byte) error {
 type BusArrival struct {
 	BusStopCode string    `json:"BusStopCode"`
 	Services    []Service `json:"Services"`
+
+	// AsOf is when the data was fetched from DataMall.
+	AsOf time.Time `json:"-"`
+	// Stale is set when cached data is served because refreshing it failed,
+	// or because it has gone unrefreshed for longer than staleAfter.
+	Stale bool `json:"-"`
 }
 
 type Service struct {
@@ -71,4 +77,30 @@ type BusRoute struct {
 	StopSequence int     `json:"StopSequence"`
 	BusStopCode  string  `json:"BusStopCode"`
 	Distance     float64 `json:"Distance"`
+
+	// First and last bus times at this stop as "HHmm" local time, or "-"
+	// when the service doesn't run that day.
+	WDFirstBus  string `json:"WD_FirstBus"`
+	WDLastBus   string `json:"WD_LastBus"`
+	SATFirstBus string `json:"SAT_FirstBus"`
+	SATLastBus  string `json:"SAT_LastBus"`
+	SUNFirstBus string `json:"SUN_FirstBus"`
+	SUNLastBus  string `json:"SUN_LastBus"`
+}
+
+// BusService is one direction of a bus service from the BusServices dataset.
+// Frequencies are DataMall's minute ranges, e.g. "08-12", or "-" when the
+// service doesn't run in that band.
+type BusService struct {
+	ServiceNo       string `json:"ServiceNo"`
+	Operator        string `json:"Operator"`
+	Direction       int    `json:"Direction"`
+	Category        string `json:"Category"`
+	OriginCode      string `json:"OriginCode"`
+	DestinationCode string `json:"DestinationCode"`
+	AMPeakFreq      string `json:"AM_Peak_Freq"`
+	AMOffpeakFreq   string `json:"AM_Offpeak_Freq"`
+	PMPeakFreq      string `json:"PM_Peak_Freq"`
+	PMOffpeakFreq   string `json:"PM_Offpeak_Freq"`
+	LoopDesc        string `json:"LoopDesc"`
 }
@@ -0,0 +1,148 @@
+// Package places is a small gazetteer of MRT stations and landmarks, bundled
+// with the binary so searches like "bishan mrt" or "vivocity" resolve to a
+// location without an external geocoder.
+package places
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:embed places.json
+var placesJSON []byte
+
+type Place struct {
+	Name string `json:"name"`
+	// Kind is "mrt" for MRT/LRT stations or "landmark".
+	Kind string `json:"kind"`
+	// Aliases are alternative names, e.g. station codes like "NS24".
+	Aliases   []string `json:"aliases,omitempty"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+}
+
+// Match is a place found by Search. Exact is set when the query named the
+// place (or one of its aliases) in full rather than by prefix.
+type Match struct {
+	Place
+	Exact bool
+}
+
+type Gazetteer struct {
+	places []Place
+	tokens [][]string // searchable words per place: name and aliases
+}
+
+// Load parses the bundled dataset.
+func Load() (*Gazetteer, error) {
+	var ps []Place
+	if err := json.Unmarshal(placesJSON, &ps); err != nil {
+		return nil, err
+	}
+	return New(ps), nil
+}
+
+func New(ps []Place) *Gazetteer {
+	g := &Gazetteer{places: ps, tokens: make([][]string, len(ps))}
+	for i, p := range ps {
+		g.tokens[i] = tokenize(p.Name)
+		for _, a := range p.Aliases {
+			g.tokens[i] = append(g.tokens[i], tokenize(a)...)
+		}
+	}
+	return g
+}
+
+// kindWords are query words that only say what kind of place is wanted,
+// like "bishan mrt"; they narrow the kind rather than match the name.
+var kindWords = map[string]string{
+	"mrt":     "mrt",
+	"lrt":     "mrt",
+	"station": "mrt",
+	"stn":     "mrt",
+}
+
+// Search returns places where every word of query is a prefix of a word in
+// the place's name or aliases. Exact matches come first, then stations
+// before landmarks, then by name.
+func (g *Gazetteer) Search(query string, limit int) []Match {
+	words := tokenize(query)
+	var kind string
+	var terms []string
+	for _, w := range words {
+		if k, ok := kindWords[w]; ok && len(words) > 1 {
+			kind = k
+			continue
+		}
+		terms = append(terms, w)
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	full := strings.Join(terms, " ")
+
+	var out []Match
+	for i, p := range g.places {
+		if kind != "" && p.Kind != kind {
+			continue
+		}
+		if !allPrefixes(terms, g.tokens[i]) {
+			continue
+		}
+		out = append(out, Match{Place: p, Exact: g.names(i, full)})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Exact != b.Exact {
+			return a.Exact
+		}
+		if a.Kind != b.Kind {
+			return a.Kind == "mrt"
+		}
+		return a.Name < b.Name
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// names reports whether query is place i's name or one of its aliases.
+func (g *Gazetteer) names(i int, query string) bool {
+	p := g.places[i]
+	if strings.Join(tokenize(p.Name), " ") == query {
+		return true
+	}
+	for _, a := range p.Aliases {
+		if strings.Join(tokenize(a), " ") == query {
+			return true
+		}
+	}
+	return false
+}
+
+func allPrefixes(terms, words []string) bool {
+	for _, t := range terms {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
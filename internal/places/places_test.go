@@ -0,0 +1,63 @@
+package places
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	g, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(g.places) == 0 {
+		t.Fatal("expected bundled places")
+	}
+	for _, p := range g.places {
+		if p.Name == "" || (p.Kind != "mrt" && p.Kind != "landmark") {
+			t.Errorf("bad place: %+v", p)
+		}
+		// Everything should be within Singapore.
+		if p.Latitude < 1.15 || p.Latitude > 1.48 || p.Longitude < 103.6 || p.Longitude > 104.1 {
+			t.Errorf("%s is outside Singapore: %v,%v", p.Name, p.Latitude, p.Longitude)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	g := New([]Place{
+		{Name: "Bishan", Kind: "mrt", Aliases: []string{"NS17", "CC15"}},
+		{Name: "Bishan Park", Kind: "landmark"},
+		{Name: "Dhoby Ghaut", Kind: "mrt", Aliases: []string{"NS24", "NE6", "CC1"}},
+		{Name: "Gardens by the Bay", Kind: "mrt", Aliases: []string{"TE22"}},
+		{Name: "Gardens by the Bay", Kind: "landmark"},
+	})
+
+	tests := []struct {
+		query string
+		want  []string // "name/kind" in order
+		exact bool     // whether the first match is exact
+	}{
+		{"bishan", []string{"Bishan/mrt", "Bishan Park/landmark"}, true},
+		{"bishan mrt", []string{"Bishan/mrt"}, true},
+		{"bish", []string{"Bishan/mrt", "Bishan Park/landmark"}, false},
+		{"dhoby", []string{"Dhoby Ghaut/mrt"}, false},
+		{"ns24", []string{"Dhoby Ghaut/mrt"}, true},
+		{"gardens bay", []string{"Gardens by the Bay/mrt", "Gardens by the Bay/landmark"}, false},
+		{"gardens by the bay", []string{"Gardens by the Bay/mrt", "Gardens by the Bay/landmark"}, true},
+		{"station", nil, false},
+		{"orchard", nil, false},
+	}
+	for _, tt := range tests {
+		got := g.Search(tt.query, 10)
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %+v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i, m := range got {
+			if m.Name+"/"+m.Kind != tt.want[i] {
+				t.Errorf("Search(%q)[%d] = %s/%s, want %s", tt.query, i, m.Name, m.Kind, tt.want[i])
+			}
+		}
+		if len(got) > 0 && got[0].Exact != tt.exact {
+			t.Errorf("Search(%q)[0].Exact = %v, want %v", tt.query, got[0].Exact, tt.exact)
+		}
+	}
+}
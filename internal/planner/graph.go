@@ -0,0 +1,120 @@
+package planner
+
+import (
+	"math"
+
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// pattern is one service direction: the ordered stops it calls at and the
+// cumulative route distance (km) at each.
+type pattern struct {
+	service   string
+	direction int
+	stops     []string
+	dist      []float64
+}
+
+// patternRef locates a stop within a pattern.
+type patternRef struct {
+	pattern int
+	pos     int
+}
+
+type stopInfo struct {
+	lat, lng float64
+}
+
+type walk struct {
+	to     string
+	meters float64
+}
+
+// graph is an in-memory view of bus_routes used for journey search.
+type graph struct {
+	patterns []pattern
+	byStop   map[string][]patternRef
+	stops    map[string]stopInfo
+	// walks lists stops within transferWalkMeters of each stop.
+	walks map[string][]walk
+}
+
+func buildGraph(routes []store.RouteStop, stops []store.Stop) *graph {
+	g := &graph{
+		byStop: make(map[string][]patternRef),
+		stops:  make(map[string]stopInfo, len(stops)),
+		walks:  make(map[string][]walk),
+	}
+	for _, st := range stops {
+		g.stops[st.Code] = stopInfo{lat: st.Latitude, lng: st.Longitude}
+	}
+
+	// routes arrive ordered by service, direction, sequence.
+	for i := 0; i < len(routes); {
+		j := i
+		p := pattern{service: routes[i].ServiceNo, direction: routes[i].Direction}
+		for j < len(routes) && routes[j].ServiceNo == p.service && routes[j].Direction == p.direction {
+			p.stops = append(p.stops, routes[j].StopCode)
+			p.dist = append(p.dist, routes[j].Distance)
+			j++
+		}
+		idx := len(g.patterns)
+		for pos, code := range p.stops {
+			g.byStop[code] = append(g.byStop[code], patternRef{pattern: idx, pos: pos})
+		}
+		g.patterns = append(g.patterns, p)
+		i = j
+	}
+
+	g.buildWalks()
+	return g
+}
+
+// buildWalks finds short walking transfers by bucketing stops into a grid
+// of roughly transferWalkMeters cells and only comparing neighbours.
+func (g *graph) buildWalks() {
+	const cellDeg = transferWalkMeters / 111_000.0
+	type cell struct{ x, y int }
+	cellOf := func(s stopInfo) cell {
+		return cell{int(math.Floor(s.lat / cellDeg)), int(math.Floor(s.lng / cellDeg))}
+	}
+
+	grid := make(map[cell][]string)
+	for code, s := range g.stops {
+		if _, served := g.byStop[code]; !served {
+			continue
+		}
+		c := cellOf(s)
+		grid[c] = append(grid[c], code)
+	}
+
+	for code, s := range g.stops {
+		if _, served := g.byStop[code]; !served {
+			continue
+		}
+		c := cellOf(s)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, other := range grid[cell{c.x + dx, c.y + dy}] {
+					if other == code {
+						continue
+					}
+					o := g.stops[other]
+					if d := haversine(s.lat, s.lng, o.lat, o.lng); d <= transferWalkMeters {
+						g.walks[code] = append(g.walks[code], walk{to: other, meters: d})
+					}
+				}
+			}
+		}
+	}
+}
+
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	const R = 6371000 // Earth radius in meters
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
+			math.Sin(dLng/2)*math.Sin(dLng/2)
+	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
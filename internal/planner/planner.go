@@ -0,0 +1,531 @@
+// Package planner finds bus journeys with up to two transfers between stops
+// or coordinates. It searches an in-memory copy of the bus_routes graph
+// round by round (one round per bus leg), estimating ride times from route
+// distance and using live arrivals for the wait at the first stop.
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+const (
+	// MaxTransfers is the most transfers an itinerary may have.
+	MaxTransfers = 2
+
+	busSpeedKmh      = 20.0 // average including stops and traffic
+	walkMetersPerMin = 80.0
+	walkDetour       = 1.25 // straight-line to walking distance
+
+	accessWalkMeters   = 500.0
+	transferWalkMeters = 250.0
+	maxAccessStops     = 6
+
+	// defaultWait is assumed whenever there is no live ETA, including
+	// every leg after the first.
+	defaultWait = 6.0
+	// transferPenalty ranks an itinerary with a transfer below a direct one
+	// that is only slightly slower.
+	transferPenalty = 3.0
+
+	maxItineraries   = 5
+	arrivalFetchConc = 4
+)
+
+var (
+	// ErrUnknownStop is returned when an endpoint names a stop that isn't
+	// served by any route.
+	ErrUnknownStop = errors.New("unknown stop")
+	// ErrNoStopsNearby is returned when no bus stop is within walking
+	// distance of a coordinate endpoint.
+	ErrNoStopsNearby = errors.New("no bus stops within walking distance")
+)
+
+type LTAClient interface {
+	GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*lta.BusArrival, error)
+}
+
+// Planner plans journeys. The route graph is loaded lazily and reloaded
+// whenever bus routes are re-synced.
+type Planner struct {
+	store *store.Store
+	lta   LTAClient
+
+	mu      sync.Mutex
+	g       *graph
+	version time.Time
+}
+
+func New(s *store.Store, client LTAClient) *Planner {
+	return &Planner{store: s, lta: client}
+}
+
+// Place is a journey endpoint: either a stop code or a coordinate.
+type Place struct {
+	StopCode string
+	Lat, Lng float64
+}
+
+// Itinerary is one way to make the journey. Minutes is the estimated door
+// to door time including waits.
+type Itinerary struct {
+	Minutes   int   `json:"minutes"`
+	Transfers int   `json:"transfers"`
+	Legs      []Leg `json:"legs"`
+
+	score float64
+}
+
+// Leg is a walk or a bus ride. From and To are stop codes; they are empty
+// for the origin or destination when those are coordinates. LiveETA marks a
+// wait taken from live arrivals rather than assumed.
+type Leg struct {
+	Mode        string  `json:"mode"`
+	ServiceNo   string  `json:"serviceNo,omitempty"`
+	Direction   int     `json:"direction,omitempty"`
+	From        string  `json:"from,omitempty"`
+	To          string  `json:"to,omitempty"`
+	Stops       int     `json:"stops,omitempty"`
+	DistanceKm  float64 `json:"distanceKm"`
+	WaitMinutes float64 `json:"waitMinutes,omitempty"`
+	Minutes     float64 `json:"minutes"`
+	LiveETA     bool    `json:"liveEta,omitempty"`
+}
+
+const (
+	kindAccess = iota
+	kindBus
+	kindWalk
+)
+
+// label is the best known arrival at a stop in a round and how it was
+// reached. Times are minutes from now.
+type label struct {
+	t    float64
+	kind int
+	prev string // boarding stop (bus) or stop walked from (walk)
+
+	pattern, boardPos, alightPos int
+	wait                         float64
+	live                         bool
+	meters                       float64
+}
+
+// round holds the labels set in one round: arrivals by bus, and arrivals by
+// a short walk from a stop reached by bus in the same round.
+type round struct {
+	bus  map[string]label
+	walk map[string]label
+}
+
+func (r round) get(stop string) (label, bool) {
+	b, okB := r.bus[stop]
+	w, okW := r.walk[stop]
+	if okW && (!okB || w.t < b.t) {
+		return w, true
+	}
+	return b, okB
+}
+
+// stops returns every stop labelled in the round.
+func (r round) stops() []string {
+	out := make([]string, 0, len(r.bus)+len(r.walk))
+	for s := range r.bus {
+		out = append(out, s)
+	}
+	for s := range r.walk {
+		if _, ok := r.bus[s]; !ok {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// liveETAs maps stop → service → minutes until each upcoming bus. A stop
+// present with no entry for a service means that service isn't running.
+type liveETAs map[string]map[string][]float64
+
+// Plan returns up to five itineraries from one place to another, ranked by
+// estimated total time with a small penalty per transfer.
+func (p *Planner) Plan(ctx context.Context, from, to Place, maxTransfers int) ([]Itinerary, error) {
+	maxTransfers = min(max(maxTransfers, 0), MaxTransfers)
+
+	g, err := p.graph()
+	if err != nil {
+		return nil, err
+	}
+	origins, err := p.access(g, from)
+	if err != nil {
+		return nil, fmt.Errorf("origin: %w", err)
+	}
+	dests, err := p.access(g, to)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	live := p.fetchLive(ctx, origins)
+
+	s := &search{g: g, live: live}
+	s.run(origins, maxTransfers+1)
+	return s.itineraries(dests), nil
+}
+
+func (p *Planner) graph() (*graph, error) {
+	version, err := p.store.RoutesSynced()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.g != nil && version.Equal(p.version) {
+		return p.g, nil
+	}
+
+	routes, err := p.store.AllRouteStops()
+	if err != nil {
+		return nil, err
+	}
+	stops, err := p.store.AllStops()
+	if err != nil {
+		return nil, err
+	}
+	p.g = buildGraph(routes, stops)
+	p.version = version
+	slog.Info("Journey planner graph loaded", "patterns", len(p.g.patterns), "stops", len(p.g.byStop))
+	return p.g, nil
+}
+
+// access returns the stops a journey can start or end at, with the
+// straight-line walking distance to each.
+func (p *Planner) access(g *graph, pl Place) (map[string]float64, error) {
+	if pl.StopCode != "" {
+		if _, ok := g.byStop[pl.StopCode]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStop, pl.StopCode)
+		}
+		return map[string]float64{pl.StopCode: 0}, nil
+	}
+
+	nearby, err := p.store.NearbyWithin(pl.Lat, pl.Lng, accessWalkMeters, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64)
+	for _, st := range nearby {
+		if len(out) == maxAccessStops {
+			break
+		}
+		if _, ok := g.byStop[st.Code]; ok {
+			out[st.Code] = st.Distance
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNoStopsNearby
+	}
+	return out, nil
+}
+
+// fetchLive looks up current arrivals at the origin stops. Stops whose
+// lookup fails are left out, so their waits fall back to defaultWait.
+func (p *Planner) fetchLive(ctx context.Context, origins map[string]float64) liveETAs {
+	var mu sync.Mutex
+	live := make(liveETAs, len(origins))
+	sem := make(chan struct{}, arrivalFetchConc)
+	var wg sync.WaitGroup
+	now := time.Now()
+	for code := range origins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			arrivals, err := p.lta.GetBusArrival(ctx, code, "")
+			if err != nil {
+				slog.Warn("Planner: failed to fetch arrivals", "code", code, "error", err)
+				return
+			}
+			byService := make(map[string][]float64, len(arrivals.Services))
+			for _, svc := range arrivals.Services {
+				var etas []float64
+				for _, nb := range []lta.NextBus{svc.NextBus, svc.NextBus2, svc.NextBus3} {
+					if !nb.EstimatedArrival.IsZero() {
+						etas = append(etas, max(nb.EstimatedArrival.Sub(now).Minutes(), 0))
+					}
+				}
+				byService[svc.ServiceNumber] = etas
+			}
+			mu.Lock()
+			live[code] = byService
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return live
+}
+
+type search struct {
+	g      *graph
+	live   liveETAs
+	rounds []round
+	best   map[string]float64
+}
+
+// run labels stops round by round. rounds[k] holds stops reached with k
+// bus legs; only maxLegs-1 rounds are needed since itineraries() adds the
+// final leg itself.
+func (s *search) run(origins map[string]float64, maxLegs int) {
+	s.best = make(map[string]float64)
+	r0 := round{bus: make(map[string]label), walk: make(map[string]label)}
+	for code, m := range origins {
+		t := walkMinutes(m)
+		r0.bus[code] = label{t: t, kind: kindAccess, meters: m}
+		s.best[code] = t
+	}
+	s.rounds = []round{r0}
+
+	for k := 1; k < maxLegs; k++ {
+		prev := s.rounds[k-1]
+		cur := round{bus: make(map[string]label), walk: make(map[string]label)}
+		for _, stop := range prev.stops() {
+			l, _ := prev.get(stop)
+			for _, ref := range s.g.byStop[stop] {
+				s.board(k, stop, l, ref, func(j int, arr float64, lb label) {
+					to := s.g.patterns[ref.pattern].stops[j]
+					if b, ok := s.best[to]; ok && arr >= b {
+						return
+					}
+					s.best[to] = arr
+					cur.bus[to] = lb
+				})
+			}
+		}
+		for _, stop := range cur.stops() {
+			l := cur.bus[stop]
+			for _, w := range s.g.walks[stop] {
+				arr := l.t + walkMinutes(w.meters)
+				if b, ok := s.best[w.to]; ok && arr >= b {
+					continue
+				}
+				s.best[w.to] = arr
+				cur.walk[w.to] = label{t: arr, kind: kindWalk, prev: stop, meters: w.meters}
+			}
+		}
+		s.rounds = append(s.rounds, cur)
+	}
+}
+
+// board considers catching the pattern in ref at stop, having arrived there
+// at l.t in round k-1, and calls visit for each later stop with the arrival
+// time and label. It skips staying on the same service across a transfer
+// and services that live data says aren't running.
+func (s *search) board(k int, stop string, l label, ref patternRef, visit func(j int, arr float64, lb label)) {
+	pat := s.g.patterns[ref.pattern]
+	if ref.pos == len(pat.stops)-1 {
+		return
+	}
+	if k > 1 && s.lastService(k-1, stop) == pat.service {
+		return
+	}
+
+	wait, live := defaultWait, false
+	if k == 1 {
+		if byService, ok := s.live[stop]; ok {
+			// A service missing from live arrivals isn't running now; one
+			// listed without ETAs falls back to the default wait.
+			etas, running := byService[pat.service]
+			if !running {
+				return
+			}
+			for _, eta := range etas {
+				if eta >= l.t {
+					wait, live = eta-l.t, true
+					break
+				}
+			}
+		}
+	}
+
+	dep := l.t + wait
+	for j := ref.pos + 1; j < len(pat.stops); j++ {
+		arr := dep + rideMinutes(pat.dist[j]-pat.dist[ref.pos])
+		visit(j, arr, label{
+			t:         arr,
+			kind:      kindBus,
+			prev:      stop,
+			pattern:   ref.pattern,
+			boardPos:  ref.pos,
+			alightPos: j,
+			wait:      wait,
+			live:      live,
+		})
+	}
+}
+
+// lastService returns the service of the last bus leg reaching stop in
+// round k, or "" if it was reached without a bus.
+func (s *search) lastService(k int, stop string) string {
+	l, ok := s.rounds[k].get(stop)
+	if !ok {
+		return ""
+	}
+	if l.kind == kindWalk {
+		l = s.rounds[k].bus[l.prev]
+	}
+	if l.kind != kindBus {
+		return ""
+	}
+	return s.g.patterns[l.pattern].service
+}
+
+// itineraries enumerates every final bus leg from a labelled stop to a
+// destination stop, then keeps the best distinct, non-dominated options.
+func (s *search) itineraries(dests map[string]float64) []Itinerary {
+	var its []Itinerary
+	for k := 1; k <= len(s.rounds); k++ {
+		prev := s.rounds[k-1]
+		for _, stop := range prev.stops() {
+			l, _ := prev.get(stop)
+			for _, ref := range s.g.byStop[stop] {
+				s.board(k, stop, l, ref, func(j int, arr float64, lb label) {
+					to := s.g.patterns[ref.pattern].stops[j]
+					m, ok := dests[to]
+					if !ok {
+						return
+					}
+					legs := append(s.legs(k-1, stop), s.busLeg(lb))
+					total := arr
+					if m > 0 {
+						legs = append(legs, walkLeg(to, "", m))
+						total += walkMinutes(m)
+					}
+					its = append(its, Itinerary{
+						Minutes:   int(math.Ceil(total)),
+						Transfers: k - 1,
+						Legs:      legs,
+						score:     total + transferPenalty*float64(k-1),
+					})
+				})
+			}
+		}
+	}
+	return rank(its)
+}
+
+// legs reconstructs how stop was reached in round k.
+func (s *search) legs(k int, stop string) []Leg {
+	var legs []Leg
+	for {
+		l, _ := s.rounds[k].get(stop)
+		switch l.kind {
+		case kindAccess:
+			if l.meters > 0 {
+				legs = append(legs, walkLeg("", stop, l.meters))
+			}
+			reverse(legs)
+			return legs
+		case kindWalk:
+			legs = append(legs, walkLeg(l.prev, stop, l.meters))
+			l = s.rounds[k].bus[l.prev]
+			stop = l.prev
+			legs = append(legs, s.busLeg(l))
+			k--
+		case kindBus:
+			legs = append(legs, s.busLeg(l))
+			stop = l.prev
+			k--
+		}
+	}
+}
+
+func (s *search) busLeg(l label) Leg {
+	pat := s.g.patterns[l.pattern]
+	km := pat.dist[l.alightPos] - pat.dist[l.boardPos]
+	return Leg{
+		Mode:        "bus",
+		ServiceNo:   pat.service,
+		Direction:   pat.direction,
+		From:        pat.stops[l.boardPos],
+		To:          pat.stops[l.alightPos],
+		Stops:       l.alightPos - l.boardPos,
+		DistanceKm:  round2(km),
+		WaitMinutes: round2(l.wait),
+		Minutes:     round2(rideMinutes(km)),
+		LiveETA:     l.live,
+	}
+}
+
+func walkLeg(from, to string, meters float64) Leg {
+	return Leg{
+		Mode:       "walk",
+		From:       from,
+		To:         to,
+		DistanceKm: round2(meters * walkDetour / 1000),
+		Minutes:    round2(walkMinutes(meters)),
+	}
+}
+
+// rank keeps the fastest itinerary per sequence of services, drops any
+// with more transfers than a faster one, and returns the best few.
+func rank(its []Itinerary) []Itinerary {
+	sort.SliceStable(its, func(i, j int) bool { return its[i].score < its[j].score })
+
+	seen := make(map[string]bool)
+	var out []Itinerary
+	for _, it := range its {
+		var sig []string
+		for _, leg := range it.Legs {
+			if leg.Mode == "bus" {
+				sig = append(sig, fmt.Sprintf("%s/%d", leg.ServiceNo, leg.Direction))
+			}
+		}
+		key := strings.Join(sig, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		dominated := false
+		for _, o := range out {
+			if o.Transfers < it.Transfers && o.Minutes <= it.Minutes {
+				dominated = true
+				break
+			}
+		}
+		if dominated {
+			continue
+		}
+		out = append(out, it)
+		if len(out) == maxItineraries {
+			break
+		}
+	}
+	return out
+}
+
+func rideMinutes(km float64) float64 {
+	return km / busSpeedKmh * 60
+}
+
+func walkMinutes(meters float64) float64 {
+	return meters * walkDetour / walkMetersPerMin
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func reverse(legs []Leg) {
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+}
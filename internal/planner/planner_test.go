@@ -0,0 +1,236 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+type mockLTA struct {
+	// services lists the services running at each stop; stops not listed
+	// fail the lookup.
+	services map[string][]string
+	eta      time.Duration
+}
+
+func (m *mockLTA) GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*lta.BusArrival, error) {
+	svcs, ok := m.services[busStopCode]
+	if !ok {
+		return nil, errors.New("upstream down")
+	}
+	res := &lta.BusArrival{BusStopCode: busStopCode}
+	for _, no := range svcs {
+		res.Services = append(res.Services, lta.Service{
+			ServiceNumber: no,
+			NextBus:       lta.NextBus{EstimatedArrival: lta.SafeTime{Time: time.Now().Add(m.eta)}},
+		})
+	}
+	return res, nil
+}
+
+// testPlanner builds a small network:
+//
+//	1: A → B → C
+//	2: B → D
+//	3: B2 → E, where B2 is ~100 m from B
+func testPlanner(t *testing.T, client LTAClient) *Planner {
+	t.Helper()
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	stops := []lta.BusStop{
+		{BusStopCode: "A", Latitude: 1.3000, Longitude: 103.8000},
+		{BusStopCode: "B", Latitude: 1.3100, Longitude: 103.8000},
+		{BusStopCode: "B2", Latitude: 1.3109, Longitude: 103.8000},
+		{BusStopCode: "C", Latitude: 1.3200, Longitude: 103.8000},
+		{BusStopCode: "D", Latitude: 1.3100, Longitude: 103.8200},
+		{BusStopCode: "E", Latitude: 1.3109, Longitude: 103.8300},
+	}
+	routes := []lta.BusRoute{
+		{ServiceNo: "1", Direction: 1, StopSequence: 1, BusStopCode: "A", Distance: 0},
+		{ServiceNo: "1", Direction: 1, StopSequence: 2, BusStopCode: "B", Distance: 1.1},
+		{ServiceNo: "1", Direction: 1, StopSequence: 3, BusStopCode: "C", Distance: 2.2},
+		{ServiceNo: "2", Direction: 1, StopSequence: 1, BusStopCode: "B", Distance: 0},
+		{ServiceNo: "2", Direction: 1, StopSequence: 2, BusStopCode: "D", Distance: 2.2},
+		{ServiceNo: "3", Direction: 1, StopSequence: 1, BusStopCode: "B2", Distance: 0},
+		{ServiceNo: "3", Direction: 1, StopSequence: 2, BusStopCode: "E", Distance: 3.3},
+	}
+	publish(t, s, stops, routes)
+	return New(s, client)
+}
+
+// publish syncs stops and routes into s as one dataset, the way the syncer
+// does. A nil slice keeps what s already has.
+func publish(t *testing.T, s *store.Store, stops []lta.BusStop, routes []lta.BusRoute) {
+	t.Helper()
+	b, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Discard()
+	if stops != nil {
+		if _, err := b.Sync(stops); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if routes != nil {
+		if _, err := b.SyncRoutes(routes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Publish(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func busServices(it Itinerary) []string {
+	var out []string
+	for _, leg := range it.Legs {
+		if leg.Mode == "bus" {
+			out = append(out, leg.ServiceNo)
+		}
+	}
+	return out
+}
+
+func TestPlanDirect(t *testing.T) {
+	p := testPlanner(t, &mockLTA{services: map[string][]string{"A": {"1"}}, eta: 2 * time.Minute})
+
+	its, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "C"}, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 1 {
+		t.Fatalf("expected 1 itinerary, got %+v", its)
+	}
+	it := its[0]
+	if it.Transfers != 0 || len(it.Legs) != 1 {
+		t.Fatalf("expected a single bus leg, got %+v", it)
+	}
+	leg := it.Legs[0]
+	if leg.ServiceNo != "1" || leg.Stops != 2 || leg.DistanceKm != 2.2 {
+		t.Errorf("unexpected leg: %+v", leg)
+	}
+	if !leg.LiveETA || leg.WaitMinutes < 1.9 || leg.WaitMinutes > 2 {
+		t.Errorf("expected a live wait of ~2 min, got %+v", leg)
+	}
+	// 2 min wait + 2.2 km at 20 km/h.
+	if it.Minutes != 9 {
+		t.Errorf("expected 9 minutes, got %d", it.Minutes)
+	}
+}
+
+func TestPlanSameStopTransfer(t *testing.T) {
+	p := testPlanner(t, &mockLTA{})
+
+	its, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "D"}, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 1 {
+		t.Fatalf("expected 1 itinerary, got %+v", its)
+	}
+	if got := busServices(its[0]); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected 1 then 2, got %v", got)
+	}
+	if its[0].Transfers != 1 {
+		t.Errorf("expected 1 transfer, got %d", its[0].Transfers)
+	}
+	if its[0].Legs[0].LiveETA {
+		t.Error("expected the default wait when live arrivals are unavailable")
+	}
+
+	none, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "D"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Errorf("expected no direct itineraries, got %+v", none)
+	}
+}
+
+func TestPlanWalkingTransfer(t *testing.T) {
+	p := testPlanner(t, &mockLTA{})
+
+	its, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "E"}, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 1 {
+		t.Fatalf("expected 1 itinerary, got %+v", its)
+	}
+	legs := its[0].Legs
+	if len(legs) != 3 || legs[1].Mode != "walk" || legs[1].From != "B" || legs[1].To != "B2" {
+		t.Fatalf("expected bus, walk B→B2, bus; got %+v", legs)
+	}
+}
+
+func TestPlanFromCoordinates(t *testing.T) {
+	p := testPlanner(t, &mockLTA{services: map[string][]string{"A": {"1"}}})
+
+	from := Place{Lat: 1.2997, Lng: 103.8000} // ~33 m from A
+	to := Place{Lat: 1.3203, Lng: 103.8000}   // ~33 m from C
+	its, err := p.Plan(context.Background(), from, to, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) == 0 {
+		t.Fatal("expected an itinerary")
+	}
+	legs := its[0].Legs
+	if len(legs) != 3 || legs[0].Mode != "walk" || legs[0].To != "A" || legs[2].Mode != "walk" || legs[2].From != "C" {
+		t.Errorf("expected walk, bus, walk; got %+v", legs)
+	}
+
+	_, err = p.Plan(context.Background(), Place{Lat: 1.4, Lng: 103.9}, to, MaxTransfers)
+	if !errors.Is(err, ErrNoStopsNearby) {
+		t.Errorf("expected ErrNoStopsNearby, got %v", err)
+	}
+}
+
+func TestPlanSkipsServicesNotRunning(t *testing.T) {
+	p := testPlanner(t, &mockLTA{services: map[string][]string{"A": {"99"}}})
+
+	its, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "C"}, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 0 {
+		t.Errorf("expected no itineraries when service 1 isn't running, got %+v", its)
+	}
+}
+
+func TestPlanUnknownStop(t *testing.T) {
+	p := testPlanner(t, &mockLTA{})
+	_, err := p.Plan(context.Background(), Place{StopCode: "ZZZ"}, Place{StopCode: "C"}, MaxTransfers)
+	if !errors.Is(err, ErrUnknownStop) {
+		t.Errorf("expected ErrUnknownStop, got %v", err)
+	}
+}
+
+func TestPlannerReloadsAfterRouteSync(t *testing.T) {
+	p := testPlanner(t, &mockLTA{})
+	if _, err := p.graph(); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, p.store, nil, []lta.BusRoute{
+		{ServiceNo: "7", Direction: 1, StopSequence: 1, BusStopCode: "A", Distance: 0},
+		{ServiceNo: "7", Direction: 1, StopSequence: 2, BusStopCode: "E", Distance: 4},
+	})
+
+	its, err := p.Plan(context.Background(), Place{StopCode: "A"}, Place{StopCode: "E"}, MaxTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 1 || busServices(its[0])[0] != "7" {
+		t.Errorf("expected the re-synced route, got %+v", its)
+	}
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"database/sql"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Change kinds recorded in the sync change log.
+const (
+	ChangeStopAdded   = "stop_added"
+	ChangeStopMoved   = "stop_moved"
+	ChangeStopRenamed = "stop_renamed"
+	ChangeStopRemoved = "stop_removed"
+
+	// ChangeRouteAdded and ChangeRouteRemoved are a whole service direction
+	// starting or ending.
+	ChangeRouteAdded   = "route_added"
+	ChangeRouteRemoved = "route_removed"
+	// ChangeRouteStopAdded and ChangeRouteStopRemoved are a service starting
+	// or ceasing to call at StopCode, e.g. when a route is extended or now
+	// skips a stop.
+	ChangeRouteStopAdded   = "route_stop_added"
+	ChangeRouteStopRemoved = "route_stop_removed"
+	// ChangeRouteResequenced is a service calling at the same stops in a
+	// different order.
+	ChangeRouteResequenced = "route_resequenced"
+)
+
+// Change is one difference between successive LTA datasets. Stop changes
+// set StopCode; route changes set ServiceNo and Direction, and StopCode
+// when a single stop is affected.
+type Change struct {
+	Kind      string `json:"kind"`
+	StopCode  string `json:"stopCode,omitempty"`
+	ServiceNo string `json:"serviceNo,omitempty"`
+	Direction int    `json:"direction,omitempty"`
+}
+
+// SyncChange is a Change as recorded by a sync run.
+type SyncChange struct {
+	RunID      int64     `json:"runId"`
+	RecordedAt time.Time `json:"recordedAt"`
+	Change
+}
+
+// Sync run statuses.
+const (
+	SyncRunning = "running"
+	SyncOK      = "ok"
+	SyncFailed  = "failed"
+)
+
+// SyncRun is one run of the LTA sync. FinishedAt is nil while it runs.
+type SyncRun struct {
+	ID         int64      `json:"id"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Status     string     `json:"status"`
+	Stops      int        `json:"stops"`
+	Routes     int        `json:"routes"`
+	Services   int        `json:"services"`
+	Changes    int        `json:"changes"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// SyncResult is what a sync run fetched and changed, as far as it got.
+type SyncResult struct {
+	Stops    int
+	Routes   int
+	Services int
+	Changes  []Change
+}
+
+// StartSyncRun records the start of a sync run and returns its ID.
+func (s *Store) StartSyncRun() (int64, error) {
+	res, err := s.db.Exec(
+		`INSERT INTO sync_runs (started_at, status) VALUES (?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), SyncRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishSyncRun records the outcome of run id and its changes. A non-nil
+// syncErr marks the run failed; changes already applied are still logged.
+func (s *Store) FinishSyncRun(id int64, result SyncResult, syncErr error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	stmt, err := tx.Prepare(`INSERT INTO sync_changes (run_id, recorded_at, kind, stop_code, service_no, direction) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, c := range result.Changes {
+		if _, err := stmt.Exec(id, now, c.Kind, c.StopCode, c.ServiceNo, c.Direction); err != nil {
+			return err
+		}
+	}
+
+	status, errMsg := SyncOK, ""
+	if syncErr != nil {
+		status, errMsg = SyncFailed, syncErr.Error()
+	}
+	if _, err := tx.Exec(`
+		UPDATE sync_runs
+		SET finished_at = ?, status = ?, stops = ?, routes = ?, services = ?, changes = ?, error = ?
+		WHERE id = ?
+	`, now, status, result.Stops, result.Routes, result.Services, len(result.Changes), errMsg, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FailInterruptedSyncRuns marks runs still recorded as running as failed.
+// It is meant for startup, when no sync can be in progress, so runs cut
+// short by a crash or restart don't show as running forever.
+func (s *Store) FailInterruptedSyncRuns() (int64, error) {
+	res, err := s.db.Exec(
+		`UPDATE sync_runs SET finished_at = ?, status = ?, error = ? WHERE status = ?`,
+		time.Now().UTC().Format(time.RFC3339), SyncFailed, "interrupted", SyncRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SyncRuns returns up to limit sync runs, newest first.
+func (s *Store) SyncRuns(limit int) ([]SyncRun, error) {
+	rows, err := s.db.Query(`
+		SELECT id, started_at, finished_at, status, stops, routes, services, changes, error
+		FROM sync_runs
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []SyncRun
+	for rows.Next() {
+		var r SyncRun
+		var started string
+		var finished sql.NullString
+		if err := rows.Scan(&r.ID, &started, &finished, &r.Status, &r.Stops, &r.Routes, &r.Services, &r.Changes, &r.Error); err != nil {
+			return nil, err
+		}
+		if r.StartedAt, err = time.Parse(time.RFC3339, started); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			t, err := time.Parse(time.RFC3339, finished.String)
+			if err != nil {
+				return nil, err
+			}
+			r.FinishedAt = &t
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// ChangesSince returns changes recorded at or after since, oldest first. A
+// non-empty serviceNo keeps only that service's route changes.
+func (s *Store) ChangesSince(since time.Time, serviceNo string) ([]SyncChange, error) {
+	rows, err := s.db.Query(`
+		SELECT run_id, recorded_at, kind, stop_code, service_no, direction
+		FROM sync_changes
+		WHERE recorded_at >= ? AND (? = '' OR service_no = ?)
+		ORDER BY id
+	`, since.UTC().Format(time.RFC3339), serviceNo, serviceNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []SyncChange
+	for rows.Next() {
+		var c SyncChange
+		var recorded string
+		if err := rows.Scan(&c.RunID, &recorded, &c.Kind, &c.StopCode, &c.ServiceNo, &c.Direction); err != nil {
+			return nil, err
+		}
+		if c.RecordedAt, err = time.Parse(time.RFC3339, recorded); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+type routeKey struct {
+	serviceNo string
+	direction int
+}
+
+// routeSequences returns the stop codes of every service direction in
+// calling order.
+func routeSequences(tx *sql.Tx) (map[routeKey][]string, error) {
+	rows, err := tx.Query(`SELECT service_no, direction, bus_stop_code FROM bus_routes ORDER BY service_no, direction, stop_sequence`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seqs := make(map[routeKey][]string)
+	for rows.Next() {
+		var k routeKey
+		var code string
+		if err := rows.Scan(&k.serviceNo, &k.direction, &code); err != nil {
+			return nil, err
+		}
+		seqs[k] = append(seqs[k], code)
+	}
+	return seqs, rows.Err()
+}
+
+// diffRoutes compares route sequences before and after a sync, ordered by
+// service and direction.
+func diffRoutes(before, after map[routeKey][]string) []Change {
+	keys := make([]routeKey, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].serviceNo != keys[j].serviceNo {
+			return keys[i].serviceNo < keys[j].serviceNo
+		}
+		return keys[i].direction < keys[j].direction
+	})
+
+	var changes []Change
+	for _, k := range keys {
+		old, hadOld := before[k]
+		cur, hasCur := after[k]
+		route := Change{ServiceNo: k.serviceNo, Direction: k.direction}
+		switch {
+		case !hadOld:
+			route.Kind = ChangeRouteAdded
+			changes = append(changes, route)
+			continue
+		case !hasCur:
+			route.Kind = ChangeRouteRemoved
+			changes = append(changes, route)
+			continue
+		case slices.Equal(old, cur):
+			continue
+		}
+
+		var stopChanges []Change
+		for _, code := range cur {
+			if !slices.Contains(old, code) {
+				stopChanges = append(stopChanges, Change{Kind: ChangeRouteStopAdded, StopCode: code, ServiceNo: k.serviceNo, Direction: k.direction})
+			}
+		}
+		for _, code := range old {
+			if !slices.Contains(cur, code) {
+				stopChanges = append(stopChanges, Change{Kind: ChangeRouteStopRemoved, StopCode: code, ServiceNo: k.serviceNo, Direction: k.direction})
+			}
+		}
+		if len(stopChanges) == 0 {
+			route.Kind = ChangeRouteResequenced
+			stopChanges = append(stopChanges, route)
+		}
+		changes = append(changes, stopChanges...)
+	}
+	return changes
+}
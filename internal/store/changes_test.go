@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	before := map[routeKey][]string{
+		{"10", 1}: {"A", "B", "C"},
+		{"14", 1}: {"A", "B", "C"},
+		{"14", 2}: {"C", "B", "A"},
+		{"21", 1}: {"A", "B"},
+		{"30", 1}: {"A", "B"},
+	}
+	after := map[routeKey][]string{
+		{"10", 1}: {"A", "B", "C"},
+		{"14", 1}: {"A", "C", "D"},
+		{"14", 2}: {"C", "A", "B"},
+		{"21", 1}: {"A", "B"},
+		{"40", 1}: {"A", "C"},
+	}
+
+	got := diffRoutes(before, after)
+	want := []Change{
+		{Kind: ChangeRouteStopAdded, StopCode: "D", ServiceNo: "14", Direction: 1},
+		{Kind: ChangeRouteStopRemoved, StopCode: "B", ServiceNo: "14", Direction: 1},
+		{Kind: ChangeRouteResequenced, ServiceNo: "14", Direction: 2},
+		{Kind: ChangeRouteRemoved, ServiceNo: "30", Direction: 1},
+		{Kind: ChangeRouteAdded, ServiceNo: "40", Direction: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSyncRoutesReportsChanges(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishRoutes(t, s, []lta.BusRoute{
+		{ServiceNo: "14", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "14", Direction: 1, StopSequence: 2, BusStopCode: "X"},
+		{ServiceNo: "14", Direction: 1, StopSequence: 3, BusStopCode: "B"},
+	})
+	changes := publishRoutes(t, s, []lta.BusRoute{
+		{ServiceNo: "14", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "14", Direction: 1, StopSequence: 2, BusStopCode: "B"},
+	})
+	want := []Change{{Kind: ChangeRouteStopRemoved, StopCode: "X", ServiceNo: "14", Direction: 1}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected 14 to skip X, got %+v", changes)
+	}
+}
+
+func TestSyncRunLog(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	start := time.Now().Add(-time.Second)
+	first, err := s.StartSyncRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FinishSyncRun(first, SyncResult{Stops: 2, Routes: 3, Changes: []Change{
+		{Kind: ChangeStopRemoved, StopCode: "C"},
+		{Kind: ChangeRouteStopRemoved, StopCode: "C", ServiceNo: "14", Direction: 1},
+	}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.StartSyncRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FinishSyncRun(second, SyncResult{Stops: 2}, errors.New("routes: timeout")); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := s.SyncRuns(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", runs)
+	}
+	if runs[0].ID != second || runs[0].Status != SyncFailed || runs[0].Error != "routes: timeout" || runs[0].FinishedAt == nil {
+		t.Errorf("expected the failed run first, got %+v", runs[0])
+	}
+	if runs[1].Status != SyncOK || runs[1].Stops != 2 || runs[1].Routes != 3 || runs[1].Changes != 2 {
+		t.Errorf("unexpected first run %+v", runs[1])
+	}
+
+	changes, err := s.ChangesSince(start, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].RunID != first || changes[0].Kind != ChangeStopRemoved {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	changes, err = s.ChangesSince(start, "14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].StopCode != "C" {
+		t.Errorf("expected only service 14's change, got %+v", changes)
+	}
+	changes, err = s.ChangesSince(time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes in the future, got %+v", changes)
+	}
+}
+
+func TestFailInterruptedSyncRuns(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	done, err := s.StartSyncRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FinishSyncRun(done, SyncResult{Stops: 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A run the process never finished.
+	if _, err := s.StartSyncRun(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.FailInterruptedSyncRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 interrupted run, got %d", n)
+	}
+	runs, err := s.SyncRuns(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs[0].Status != SyncFailed || runs[0].Error != "interrupted" || runs[0].FinishedAt == nil {
+		t.Errorf("expected the unfinished run failed as interrupted, got %+v", runs[0])
+	}
+	if runs[1].Status != SyncOK {
+		t.Errorf("expected the finished run left alone, got %+v", runs[1])
+	}
+}
@@ -0,0 +1,423 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// LTA data (stops, routes, services and their search and spatial indexes)
+// is versioned as a whole. Each sync builds a new dataset in its own SQLite
+// file beside the main database, which holds only the LTA data tables
+// (migration.up), starting from a copy of the live one, and
+// Publish then switches every reader to it at once. The dataset it replaced
+// is kept for RollbackDataset. The registry lives in the main database's
+// datasets table; the dataset the main database itself held before
+// versioning is registered with an empty path.
+
+// Dataset states in the registry.
+const (
+	DatasetBuilding = "building"
+	DatasetLive     = "live"
+	DatasetPrevious = "previous"
+	DatasetRetired  = "retired"
+)
+
+// ErrNoPreviousDataset is returned by RollbackDataset when there is no
+// dataset to go back to.
+var ErrNoPreviousDataset = errors.New("no previous dataset")
+
+// datasetTables are copied into each new dataset, so a sync diffs against
+// the live data and keeps what LTA doesn't resend, like retired stops and
+// operators learned from arrivals. Operators learned while a build is open
+// are written to it too; see UpsertServiceOperator.
+var datasetTables = []string{"bus_stops", "bus_routes", "bus_services", "bus_service_directions", "meta"}
+
+// DatasetVersion is a dataset in the registry.
+type DatasetVersion struct {
+	Version   int64     `json:"version"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// openDatasets opens the live and previous datasets and clears out builds
+// left behind by a crash.
+func (s *Store) openDatasets() error {
+	rows, err := s.db.Query(`SELECT version, path, state FROM datasets WHERE state IN (?, ?, ?)`,
+		DatasetBuilding, DatasetLive, DatasetPrevious)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		version     int64
+		path, state string
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.version, &e.path, &e.state); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.live.Store(s.db)
+	for _, e := range entries {
+		switch e.state {
+		case DatasetBuilding:
+			s.retireDataset(e.version, e.path)
+		case DatasetLive:
+			db, err := s.datasetDB(e.path)
+			if err != nil {
+				return fmt.Errorf("open dataset %d: %w", e.version, err)
+			}
+			s.live.Store(db)
+		case DatasetPrevious:
+			db, err := s.datasetDB(e.path)
+			if err != nil {
+				slog.Warn("Failed to open previous dataset, rollback unavailable", "version", e.version, "error", err)
+				continue
+			}
+			s.prev = db
+		}
+	}
+	return nil
+}
+
+func (s *Store) datasetDB(path string) (*sql.DB, error) {
+	if path == "" {
+		return s.db, nil
+	}
+	if _, err := os.Stat(path); err != nil && path != ":memory:" {
+		return nil, err
+	}
+	return openDB(path, true)
+}
+
+// datasetPath is where dataset version lives. An in-memory store keeps its
+// datasets in memory too.
+func (s *Store) datasetPath(version int64) string {
+	if s.path == ":memory:" {
+		return ":memory:"
+	}
+	return fmt.Sprintf("%s.dataset-%d", s.path, version)
+}
+
+// retireDataset marks a dataset retired and deletes its file. Failures are
+// only logged: a leftover file wastes space but breaks nothing.
+func (s *Store) retireDataset(version int64, path string) {
+	if _, err := s.db.Exec(`UPDATE datasets SET state = ? WHERE version = ?`, DatasetRetired, version); err != nil {
+		slog.Warn("Failed to retire dataset", "version", version, "error", err)
+	}
+	removeDatasetFile(version, path)
+}
+
+func removeDatasetFile(version int64, path string) {
+	if path == "" || path == ":memory:" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Failed to delete dataset file", "version", version, "path", path, "error", err)
+	}
+}
+
+// retiredDataset is a dataset already retired in the registry whose handle
+// and file are kept for readers still using it.
+type retiredDataset struct {
+	db      *sql.DB
+	version int64
+	path    string
+}
+
+// close closes the dataset and deletes its file. It is safe on nil.
+func (r *retiredDataset) close() {
+	if r == nil {
+		return
+	}
+	if r.db != nil {
+		r.db.Close()
+	}
+	removeDatasetFile(r.version, r.path)
+}
+
+// Datasets lists the datasets not yet retired, newest first.
+func (s *Store) Datasets() ([]DatasetVersion, error) {
+	rows, err := s.db.Query(`SELECT version, state, created_at FROM datasets WHERE state != ? ORDER BY version DESC`, DatasetRetired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []DatasetVersion
+	for rows.Next() {
+		var v DatasetVersion
+		var created string
+		if err := rows.Scan(&v.Version, &v.State, &created); err != nil {
+			return nil, err
+		}
+		if v.CreatedAt, err = time.Parse(time.RFC3339, created); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// RollbackDataset makes the previous dataset live again and returns it.
+// The dataset it replaces becomes the previous one, so a second rollback
+// undoes the first.
+func (s *Store) RollbackDataset() (DatasetVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var v DatasetVersion
+	if s.prev == nil {
+		return v, ErrNoPreviousDataset
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return v, err
+	}
+	defer tx.Rollback()
+
+	var created string
+	if err := tx.QueryRow(`SELECT version, created_at FROM datasets WHERE state = ?`, DatasetPrevious).Scan(&v.Version, &created); err != nil {
+		if err == sql.ErrNoRows {
+			return v, ErrNoPreviousDataset
+		}
+		return v, err
+	}
+	if v.CreatedAt, err = time.Parse(time.RFC3339, created); err != nil {
+		return v, err
+	}
+	if _, err := tx.Exec(`UPDATE datasets SET state = ? WHERE state = ?`, DatasetPrevious, DatasetLive); err != nil {
+		return v, err
+	}
+	if _, err := tx.Exec(`UPDATE datasets SET state = ? WHERE version = ?`, DatasetLive, v.Version); err != nil {
+		return v, err
+	}
+	if err := tx.Commit(); err != nil {
+		return v, err
+	}
+
+	v.State = DatasetLive
+	prev := s.prev
+	s.prev = s.live.Swap(prev)
+	return v, nil
+}
+
+// DatasetBuild is a dataset being written by a sync. Readers don't see any
+// of it until Publish.
+type DatasetBuild struct {
+	s       *Store
+	version int64
+	path    string
+	db      *sql.DB
+	done    bool
+}
+
+// BeginDataset starts a new dataset from a copy of the live one.
+func (s *Store) BeginDataset() (*DatasetBuild, error) {
+	res, err := s.db.Exec(`INSERT INTO datasets (path, state, created_at) VALUES ('', ?, ?)`,
+		DatasetBuilding, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	version, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	b := &DatasetBuild{s: s, version: version, path: s.datasetPath(version)}
+	if _, err := s.db.Exec(`UPDATE datasets SET path = ? WHERE version = ?`, b.path, version); err != nil {
+		b.Discard()
+		return nil, err
+	}
+
+	if b.db, err = openDB(b.path, true); err != nil {
+		b.Discard()
+		return nil, err
+	}
+	// Copying under s.mu means every operator UpsertServiceOperator learns
+	// lands either in the copy or, once b is registered, in b directly.
+	s.mu.Lock()
+	err = copyDataset(s.data(), b.db)
+	if err == nil {
+		s.build = b
+	}
+	s.mu.Unlock()
+	if err != nil {
+		b.Discard()
+		return nil, fmt.Errorf("copy live dataset: %w", err)
+	}
+	return b, nil
+}
+
+// copyDataset copies datasetTables from one database into an empty one and
+// indexes the copied stops for search. The spatial index fills itself
+// through its triggers.
+func copyDataset(from, to *sql.DB) error {
+	tx, err := to.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range datasetTables {
+		if err := copyTable(from, tx, table); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	if err := rebuildStopSearch(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func copyTable(from *sql.DB, to *sql.Tx, table string) error {
+	rows, err := from.Query(`SELECT * FROM ` + table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	stmt, err := to.Prepare(`INSERT INTO ` + table + ` (` + strings.Join(cols, ", ") + `) VALUES (?` +
+		strings.Repeat(", ?", len(cols)-1) + `)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(vals...); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Version is the build's dataset version.
+func (b *DatasetBuild) Version() int64 {
+	return b.version
+}
+
+// Sync upserts stops and retires any active stop missing from them, so
+// links to a decommissioned stop can still be answered.
+func (b *DatasetBuild) Sync(stops []lta.BusStop) (StopDiff, error) {
+	return syncStops(b.db, stops)
+}
+
+// SyncRoutes replaces all bus routes and returns how each service's routes
+// differ from the ones replaced.
+func (b *DatasetBuild) SyncRoutes(routes []lta.BusRoute) ([]Change, error) {
+	return syncRoutes(b.db, routes)
+}
+
+// SyncServices replaces the service directions and records each service's
+// operator.
+func (b *DatasetBuild) SyncServices(services []lta.BusService) error {
+	return syncServices(b.db, services)
+}
+
+// SeedServiceOperators lists every routed service in bus_services, with no
+// operator until one is learned.
+func (b *DatasetBuild) SeedServiceOperators() error {
+	return seedServiceOperators(b.db)
+}
+
+// Publish makes the build the live dataset for every reader at once. The
+// live dataset becomes the previous one, and the one before that is
+// retired. A retired dataset is only closed and deleted by the next
+// Publish, long after any reader that picked it up before the swap.
+func (b *DatasetBuild) Publish() error {
+	if b.done {
+		return errors.New("dataset build already finished")
+	}
+	s := b.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldVersion int64
+	var oldPath string
+	err = tx.QueryRow(`SELECT version, path FROM datasets WHERE state = ?`, DatasetPrevious).Scan(&oldVersion, &oldPath)
+	hasOld := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, q := range []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE datasets SET state = ? WHERE state = ?`, []any{DatasetRetired, DatasetPrevious}},
+		{`UPDATE datasets SET state = ? WHERE state = ?`, []any{DatasetPrevious, DatasetLive}},
+		{`UPDATE datasets SET state = ? WHERE version = ?`, []any{DatasetLive, b.version}},
+	} {
+		if _, err := tx.Exec(q.query, q.args...); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	b.done = true
+	s.build = nil
+
+	old := s.prev
+	s.prev = s.live.Swap(b.db)
+	s.retired.close()
+	s.retired = nil
+	if hasOld {
+		s.retired = &retiredDataset{version: oldVersion, path: oldPath}
+		if old != s.db {
+			s.retired.db = old
+		}
+	}
+	return nil
+}
+
+// Discard drops an unpublished build. It does nothing once the build is
+// published, so it can be deferred.
+func (b *DatasetBuild) Discard() {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.s.mu.Lock()
+	if b.s.build == b {
+		b.s.build = nil
+	}
+	b.s.mu.Unlock()
+	if b.db != nil {
+		b.db.Close()
+	}
+	b.s.retireDataset(b.version, b.path)
+}
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func liveStopCodes(t *testing.T, s *Store) []string {
+	t.Helper()
+	codes, err := s.GetAllStopCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codes
+}
+
+func TestDatasetPublishAndRollback(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishStops(t, s, []lta.BusStop{{BusStopCode: "A", Latitude: 1.3, Longitude: 103.8}})
+	publishRoutes(t, s, []lta.BusRoute{{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A"}})
+
+	build, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := build.Sync([]lta.BusStop{
+		{BusStopCode: "A", Latitude: 1.3, Longitude: 103.8},
+		{BusStopCode: "B", Latitude: 1.301, Longitude: 103.8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "B" {
+		t.Errorf("expected the build to diff against the live data, got %+v", diff)
+	}
+	changes, err := build.SyncRoutes([]lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "B"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != ChangeRouteStopAdded {
+		t.Errorf("expected 10 extended to B, got %+v", changes)
+	}
+
+	// Nothing is visible before Publish.
+	if codes := liveStopCodes(t, s); len(codes) != 1 {
+		t.Errorf("expected the build hidden from readers, got %v", codes)
+	}
+	if err := build.Publish(); err != nil {
+		t.Fatal(err)
+	}
+	if codes := liveStopCodes(t, s); len(codes) != 2 {
+		t.Errorf("expected the published stops, got %v", codes)
+	}
+	if stops, err := s.NearbyWithin(1.301, 103.8, 50, 0); err != nil || len(stops) != 1 || stops[0].Code != "B" {
+		t.Errorf("expected the spatial index copied and updated, got %+v, %v", stops, err)
+	}
+	if stops, err := s.SearchStops("A", 10); err != nil || len(stops) != 1 {
+		t.Errorf("expected the search index built, got %+v, %v", stops, err)
+	}
+
+	versions, err := s.Datasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != build.Version() || versions[0].State != DatasetLive || versions[1].State != DatasetPrevious {
+		t.Errorf("unexpected datasets %+v", versions)
+	}
+
+	v, err := s.RollbackDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != versions[1].Version {
+		t.Errorf("expected version %d live again, got %+v", versions[1].Version, v)
+	}
+	if codes := liveStopCodes(t, s); len(codes) != 1 {
+		t.Errorf("expected the previous stops after rollback, got %v", codes)
+	}
+
+	// Rolling back again undoes the rollback.
+	if _, err := s.RollbackDataset(); err != nil {
+		t.Fatal(err)
+	}
+	if codes := liveStopCodes(t, s); len(codes) != 2 {
+		t.Errorf("expected the newer stops again, got %v", codes)
+	}
+}
+
+func TestDatasetDiscard(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.RollbackDataset(); !errors.Is(err, ErrNoPreviousDataset) {
+		t.Errorf("expected ErrNoPreviousDataset, got %v", err)
+	}
+
+	build, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := build.Sync([]lta.BusStop{{BusStopCode: "A"}}); err != nil {
+		t.Fatal(err)
+	}
+	build.Discard()
+
+	if codes := liveStopCodes(t, s); len(codes) != 0 {
+		t.Errorf("expected a discarded build to leave no trace, got %v", codes)
+	}
+	versions, err := s.Datasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].State != DatasetLive {
+		t.Errorf("expected only the original dataset, got %+v", versions)
+	}
+}
+
+func TestOperatorLearnedDuringBuildSurvivesPublish(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	build, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertServiceOperator("10", "SBST"); err != nil {
+		t.Fatal(err)
+	}
+	if err := build.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	op, err := s.GetServiceOperator("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != "SBST" {
+		t.Errorf("expected the operator learned during the build to be kept, got %q", op)
+	}
+
+	// With no build open, it only goes to the live dataset.
+	if err := s.UpsertServiceOperator("14", "SMRT"); err != nil {
+		t.Fatal(err)
+	}
+	if op, _ := s.GetServiceOperator("14"); op != "SMRT" {
+		t.Errorf("expected SMRT for 14, got %q", op)
+	}
+}
+
+func TestDatasetFileSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	build, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer build.Discard()
+
+	tables := make(map[string]bool)
+	rows, err := build.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables[name] = true
+	}
+	for _, name := range datasetTables {
+		if !tables[name] {
+			t.Errorf("expected dataset table %s in the dataset file", name)
+		}
+	}
+	for _, name := range []string{"users", "sync_runs", "sync_changes", "datasets"} {
+		if tables[name] {
+			t.Errorf("expected no %s table in the dataset file", name)
+		}
+	}
+
+	var version int
+	if err := build.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != migrations[len(migrations)-1].Version {
+		t.Errorf("expected the dataset file at the latest schema version, got %d", version)
+	}
+}
+
+func TestRetiredDatasetOutlivesPublish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publish := func(code string) {
+		t.Helper()
+		build, err := s.BeginDataset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := build.Sync([]lta.BusStop{{BusStopCode: code}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := build.Publish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A reader picks up the dataset made live by a rollback and is still
+	// using it when two publishes retire it.
+	publish("A")
+	publish("B")
+	if _, err := s.RollbackDataset(); err != nil {
+		t.Fatal(err)
+	}
+	inFlight := s.data()
+	publish("C")
+	publish("D")
+
+	var code string
+	if err := inFlight.QueryRow(`SELECT code FROM bus_stops`).Scan(&code); err != nil {
+		t.Fatalf("expected the retired dataset to stay readable, got %v", err)
+	}
+	if code != "A" {
+		t.Errorf("expected stop A in the retired dataset, got %q", code)
+	}
+
+	// The next publish finally closes it.
+	publish("E")
+	if err := inFlight.Ping(); err == nil {
+		t.Error("expected the retired dataset to be closed by the next publish")
+	}
+}
+
+func TestDatasetsSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	var published []string
+	for _, code := range []string{"A", "B", "C"} {
+		build, err := s.BeginDataset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := build.Sync([]lta.BusStop{{BusStopCode: code}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := build.Publish(); err != nil {
+			t.Fatal(err)
+		}
+		published = append(published, s.datasetPath(build.Version()))
+	}
+	s.Close()
+
+	// Only the live and previous dataset files are kept.
+	if _, err := os.Stat(published[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the oldest dataset file deleted, got %v", err)
+	}
+
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer s.Close()
+	if codes := liveStopCodes(t, s); len(codes) != 1 || codes[0] != "C" {
+		t.Errorf("expected the live dataset after reopening, got %v", codes)
+	}
+	if _, err := s.RollbackDataset(); err != nil {
+		t.Fatal(err)
+	}
+	if codes := liveStopCodes(t, s); len(codes) != 1 || codes[0] != "B" {
+		t.Errorf("expected the previous dataset after rollback, got %v", codes)
+	}
+}
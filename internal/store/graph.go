@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RouteStop is one row of bus_routes: a service calling at a stop.
+type RouteStop struct {
+	ServiceNo string
+	Direction int
+	Sequence  int
+	StopCode  string
+	Distance  float64
+}
+
+// AllRouteStops returns every route row ordered by service, direction and
+// sequence, for building an in-memory route graph.
+func (s *Store) AllRouteStops() ([]RouteStop, error) {
+	rows, err := s.data().Query(`
+		SELECT service_no, direction, stop_sequence, bus_stop_code, distance
+		FROM bus_routes
+		ORDER BY service_no, direction, stop_sequence
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RouteStop
+	for rows.Next() {
+		var r RouteStop
+		if err := rows.Scan(&r.ServiceNo, &r.Direction, &r.Sequence, &r.StopCode, &r.Distance); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// AllStops returns every bus stop in service.
+func (s *Store) AllStops() ([]Stop, error) {
+	rows, err := s.data().Query(`SELECT code, road_name, description, latitude, longitude FROM bus_stops WHERE retired_at IS NULL ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Stop
+	for rows.Next() {
+		var st Stop
+		if err := rows.Scan(&st.Code, &st.RoadName, &st.Description, &st.Latitude, &st.Longitude); err != nil {
+			return nil, err
+		}
+		results = append(results, st)
+	}
+	return results, rows.Err()
+}
+
+// RoutesSynced returns when bus routes were last replaced, or the zero time
+// if they never have been.
+func (s *Store) RoutesSynced() (time.Time, error) {
+	var val string
+	err := s.data().QueryRow(`SELECT value FROM meta WHERE key = 'routes_synced'`).Scan(&val)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, val)
+}
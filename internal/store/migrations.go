@@ -0,0 +1,270 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Migration identifies one schema change. Versions are applied in order
+// and recorded in schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+}
+
+type migration struct {
+	Migration
+	// up changes the LTA data tables, and runs on the main database and on
+	// every dataset file.
+	up func(tx *sql.Tx) error
+	// main changes tables that only the main database has: users, the sync
+	// log and the dataset registry. Dataset files skip it.
+	main func(tx *sql.Tx) error
+}
+
+// migrations is the full schema history. Append new migrations to the end
+// with the next version; never edit or reorder applied ones. Databases
+// created before migrations were tracked have no schema_migrations table,
+// so every migration up to that point must tolerate its change already
+// being present.
+var migrations = []migration{
+	{Migration: Migration{1, "baseline"}, up: execMigration(`
+		CREATE TABLE IF NOT EXISTS bus_stops (
+			code        TEXT PRIMARY KEY,
+			road_name   TEXT NOT NULL,
+			description TEXT NOT NULL,
+			latitude    REAL NOT NULL,
+			longitude   REAL NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS meta (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS bus_routes (
+			service_no   TEXT NOT NULL,
+			direction    INTEGER NOT NULL,
+			stop_sequence INTEGER NOT NULL,
+			bus_stop_code TEXT NOT NULL,
+			distance     REAL NOT NULL,
+			PRIMARY KEY (service_no, direction, stop_sequence)
+		);
+		CREATE INDEX IF NOT EXISTS idx_bus_routes_service ON bus_routes(service_no);
+		CREATE TABLE IF NOT EXISTS bus_services (
+			service_no TEXT PRIMARY KEY,
+			operator   TEXT NOT NULL
+		);
+	`), main: execMigration(`
+		CREATE TABLE IF NOT EXISTS users (
+			id         TEXT PRIMARY KEY,
+			phrase     TEXT UNIQUE NOT NULL,
+			token      TEXT UNIQUE NOT NULL,
+			config     TEXT NOT NULL DEFAULT '[]',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_users_phrase ON users(phrase);
+		CREATE INDEX IF NOT EXISTS idx_users_token  ON users(token);
+	`)},
+	{Migration: Migration{2, "bus_routes_first_last_bus"}, up: func(tx *sql.Tx) error {
+		for _, col := range []string{"wd_first_bus", "wd_last_bus", "sat_first_bus", "sat_last_bus", "sun_first_bus", "sun_last_bus"} {
+			if err := addColumnIfMissing(tx, "bus_routes", col, "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Migration: Migration{3, "bus_routes_stop_index"}, up: execMigration(`
+		CREATE INDEX IF NOT EXISTS idx_bus_routes_stop ON bus_routes(bus_stop_code);
+	`)},
+	{Migration: Migration{4, "bus_service_directions"}, up: execMigration(`
+		CREATE TABLE IF NOT EXISTS bus_service_directions (
+			service_no       TEXT NOT NULL,
+			direction        INTEGER NOT NULL,
+			operator         TEXT NOT NULL,
+			category         TEXT NOT NULL,
+			origin_code      TEXT NOT NULL,
+			destination_code TEXT NOT NULL,
+			am_peak_freq     TEXT NOT NULL,
+			am_offpeak_freq  TEXT NOT NULL,
+			pm_peak_freq     TEXT NOT NULL,
+			pm_offpeak_freq  TEXT NOT NULL,
+			loop_desc        TEXT NOT NULL,
+			PRIMARY KEY (service_no, direction)
+		);
+	`)},
+	{Migration: Migration{5, "bus_stops_search"}, up: func(tx *sql.Tx) error {
+		if _, err := tx.Exec(stopSearchSchema); err != nil {
+			return err
+		}
+		return rebuildStopSearch(tx)
+	}},
+	{Migration: Migration{6, "bus_stops_spatial_index"}, up: func(tx *sql.Tx) error {
+		if _, err := tx.Exec(stopSpatialSchema); err != nil {
+			return err
+		}
+		return rebuildStopSpatial(tx)
+	}},
+	{Migration: Migration{7, "bus_stops_retired_at"}, up: func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "bus_stops", "retired_at", "TEXT")
+	}},
+	{Migration: Migration{8, "sync_runs"}, main: execMigration(`
+		CREATE TABLE sync_runs (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			started_at  TEXT NOT NULL,
+			finished_at TEXT,
+			status      TEXT NOT NULL,
+			stops       INTEGER NOT NULL DEFAULT 0,
+			routes      INTEGER NOT NULL DEFAULT 0,
+			services    INTEGER NOT NULL DEFAULT 0,
+			changes     INTEGER NOT NULL DEFAULT 0,
+			error       TEXT NOT NULL DEFAULT ''
+		);
+		CREATE TABLE sync_changes (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id      INTEGER NOT NULL REFERENCES sync_runs(id),
+			recorded_at TEXT NOT NULL,
+			kind        TEXT NOT NULL,
+			stop_code   TEXT NOT NULL DEFAULT '',
+			service_no  TEXT NOT NULL DEFAULT '',
+			direction   INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX idx_sync_changes_recorded ON sync_changes(recorded_at);
+	`)},
+	// Registers the LTA data already in this database as the live dataset.
+	{Migration: Migration{9, "datasets"}, main: execMigration(`
+		CREATE TABLE datasets (
+			version    INTEGER PRIMARY KEY AUTOINCREMENT,
+			path       TEXT NOT NULL,
+			state      TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		);
+		INSERT INTO datasets (path, state, created_at) VALUES ('', 'live', strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+	`)},
+}
+
+func execMigration(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// migrate applies pending migrations, each in its own transaction together
+// with its schema_migrations row, so a failure leaves the database at the
+// last version that fully applied. A dataset file only gets the LTA data
+// tables, but records every version so it stays in step with the main
+// database.
+func migrate(db *sql.DB, dataset bool) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if err := applyMigration(db, m, dataset); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		slog.Info("Applied schema migration", "version", m.Version, "name", m.Name)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration, dataset bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.up != nil {
+		if err := m.up(tx); err != nil {
+			return err
+		}
+	}
+	if m.main != nil && !dataset {
+		if err := m.main(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pendingMigrations returns the migrations newer than the database's
+// version. A database without schema_migrations has none applied.
+func pendingMigrations(db *sql.DB) ([]migration, error) {
+	var exists int
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	current := 0
+	if exists > 0 {
+		if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// PendingMigrations reports the migrations New would apply to the database
+// at dbPath, without changing it.
+func PendingMigrations(dbPath string) ([]Migration, error) {
+	var pending []migration
+	// Opening a missing file would create it.
+	if _, err := os.Stat(dbPath); errors.Is(err, fs.ErrNotExist) {
+		pending = migrations
+	} else {
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if pending, err = pendingMigrations(db); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]Migration, len(pending))
+	for i, m := range pending {
+		out[i] = m.Migration
+	}
+	return out, nil
+}
+
+func addColumnIfMissing(tx *sql.Tx, table, column, decl string) error {
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + decl)
+	return err
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//go:embed testdata/baseline.sql
+var baselineFixture string
+
+// baselineDB writes a database in the pre-migration baseline schema and
+// returns its path.
+func baselineDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(baselineFixture); err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	return path
+}
+
+func appliedVersions(t *testing.T, s *Store) []int {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func TestMigrationVersionsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestUpgradeBaseline(t *testing.T) {
+	path := baselineDB(t)
+
+	pending, err := PendingMigrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(migrations) {
+		t.Fatalf("expected all %d migrations pending, got %+v", len(migrations), pending)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to upgrade baseline: %v", err)
+	}
+	defer s.Close()
+
+	if got := appliedVersions(t, s); len(got) != len(migrations) {
+		t.Errorf("expected %d applied migrations, got %v", len(migrations), got)
+	}
+
+	// Existing data survives.
+	stop, err := s.GetStop("53009")
+	if err != nil || stop == nil || stop.Description != "Bishan Int" {
+		t.Errorf("expected stop 53009 to survive, got %+v (%v)", stop, err)
+	}
+	user, err := s.UserByToken("tok1")
+	if err != nil || user == nil {
+		t.Errorf("expected user to survive, got %+v (%v)", user, err)
+	}
+
+	// New columns and tables are usable.
+	stops, err := s.GetStopsByService("53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stops) != 2 || stops[0].WDFirstBus != "" {
+		t.Errorf("unexpected route after upgrade: %+v", stops)
+	}
+	if _, err := s.GetServiceDirections("53"); err != nil {
+		t.Errorf("bus_service_directions unusable: %v", err)
+	}
+
+	// Stops already in the database are indexed.
+	if got, _ := s.SearchStops("opposite", 10); len(got) != 1 {
+		t.Errorf("expected existing stops to be searchable, got %+v", got)
+	}
+	if got, _ := s.NearbyWithin(1.3508, 103.8484, 100, 0); len(got) != 1 {
+		t.Errorf("expected existing stops in the spatial index, got %+v", got)
+	}
+
+	pending, err = PendingMigrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected nothing pending after upgrade, got %+v", pending)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fresh.db")
+	for range 2 {
+		s, err := New(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := appliedVersions(t, s); len(got) != len(migrations) {
+			t.Errorf("expected %d applied migrations, got %v", len(migrations), got)
+		}
+		s.Close()
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	path := baselineDB(t)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = []migration{
+		saved[0],
+		{Migration: Migration{2, "broken"}, up: execMigration(`
+			CREATE TABLE half_done (id INTEGER);
+			INSERT INTO no_such_table VALUES (1);
+		`)},
+	}
+
+	if err := migrate(db, false); err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("expected the database to stay at version 1, got %d", version)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("expected the broken migration's changes to be rolled back")
+	}
+}
+
+func TestPendingMigrationsMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	pending, err := PendingMigrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(migrations) {
+		t.Errorf("expected all migrations pending, got %+v", pending)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the dry run not to create %s", path)
+	}
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopAbbreviations maps the abbreviations LTA uses in stop descriptions to
+// the words people actually type. Both forms are indexed and searched, so
+// "opposite" finds "Opp Blk 123" and "stn" finds "Bishan Station".
+var stopAbbreviations = map[string]string{
+	"opp": "opposite",
+	"aft": "after",
+	"bef": "before",
+	"blk": "block",
+	"stn": "station",
+	"int": "interchange",
+	"ter": "terminal",
+	"sch": "school",
+	"ctr": "centre",
+}
+
+// stopSearchSchema creates the FTS5 index over bus_stops. aliases holds the
+// expanded forms of any abbreviations in the other columns. The fts5vocab
+// table exposes the indexed terms for typo-tolerant matching.
+const stopSearchSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS bus_stops_fts USING fts5(
+		code, description, road_name, aliases,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+	CREATE VIRTUAL TABLE IF NOT EXISTS bus_stops_fts_vocab USING fts5vocab(bus_stops_fts, 'row');
+`
+
+// rebuildStopSearch replaces the search index with the current bus_stops.
+func rebuildStopSearch(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM bus_stops_fts`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT code, description, road_name FROM bus_stops`)
+	if err != nil {
+		return err
+	}
+	var stops [][3]string
+	for rows.Next() {
+		var st [3]string
+		if err := rows.Scan(&st[0], &st[1], &st[2]); err != nil {
+			rows.Close()
+			return err
+		}
+		stops = append(stops, st)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO bus_stops_fts (code, description, road_name, aliases) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, st := range stops {
+		var aliases []string
+		for _, tok := range searchTokens(st[1] + " " + st[2]) {
+			if full, ok := stopAbbreviations[tok]; ok {
+				aliases = append(aliases, full)
+			}
+		}
+		if _, err := stmt.Exec(st[0], st[1], st[2], strings.Join(aliases, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchStops finds stops whose code, description or road name match every
+// word of query as a prefix. If nothing matches, each word may also match
+// indexed terms within a small edit distance, so "bishna" still finds
+// Bishan. An exact stop code match always ranks first.
+func (s *Store) SearchStops(query string, limit int) ([]Stop, error) {
+	tokens := searchTokens(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	results, err := s.matchStops(prefixMatch(tokens), strings.TrimSpace(query), limit)
+	if err != nil || len(results) > 0 {
+		return results, err
+	}
+
+	fuzzy, err := s.fuzzyMatch(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return s.matchStops(fuzzy, strings.TrimSpace(query), limit)
+}
+
+func (s *Store) matchStops(match, code string, limit int) ([]Stop, error) {
+	rows, err := s.data().Query(`
+		SELECT s.code, s.road_name, s.description, s.latitude, s.longitude
+		FROM bus_stops_fts f
+		JOIN bus_stops s ON s.code = f.code
+		WHERE bus_stops_fts MATCH ? AND s.retired_at IS NULL
+		ORDER BY s.code = ? DESC, bm25(bus_stops_fts), s.code
+		LIMIT ?
+	`, match, code, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Stop
+	for rows.Next() {
+		var st Stop
+		if err := rows.Scan(&st.Code, &st.RoadName, &st.Description, &st.Latitude, &st.Longitude); err != nil {
+			return nil, err
+		}
+		results = append(results, st)
+	}
+	return results, rows.Err()
+}
+
+// prefixMatch builds an FTS5 query requiring every token as a prefix,
+// also accepting the expansion of abbreviated tokens.
+func prefixMatch(tokens []string) string {
+	terms := make([]string, len(tokens))
+	for i, tok := range tokens {
+		if full, ok := stopAbbreviations[tok]; ok {
+			terms[i] = fmt.Sprintf(`("%s"* OR "%s"*)`, tok, full)
+		} else {
+			terms[i] = fmt.Sprintf(`"%s"*`, tok)
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// maxFuzzyTerms caps how many similarly spelled terms a token expands to.
+const maxFuzzyTerms = 8
+
+// fuzzyMatch builds an FTS5 query that accepts, for each token, its prefix
+// matches plus indexed terms within editBudget of it. Short and numeric
+// tokens only match as prefixes.
+func (s *Store) fuzzyMatch(tokens []string) (string, error) {
+	terms := make([]string, len(tokens))
+	for i, tok := range tokens {
+		terms[i] = fmt.Sprintf(`"%s"*`, tok)
+		budget := editBudget(tok)
+		if budget == 0 {
+			continue
+		}
+
+		n := len([]rune(tok))
+		rows, err := s.data().Query(
+			`SELECT term FROM bus_stops_fts_vocab WHERE length(term) BETWEEN ? AND ?`,
+			n-budget, n+budget,
+		)
+		if err != nil {
+			return "", err
+		}
+		type candidate struct {
+			term string
+			dist int
+		}
+		var cands []candidate
+		for rows.Next() {
+			var term string
+			if err := rows.Scan(&term); err != nil {
+				rows.Close()
+				return "", err
+			}
+			if d := editDistance(tok, term); d <= budget {
+				cands = append(cands, candidate{term, d})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+
+		sort.Slice(cands, func(a, b int) bool {
+			if cands[a].dist != cands[b].dist {
+				return cands[a].dist < cands[b].dist
+			}
+			return cands[a].term < cands[b].term
+		})
+		alts := []string{terms[i]}
+		for _, c := range cands[:min(len(cands), maxFuzzyTerms)] {
+			alts = append(alts, fmt.Sprintf(`"%s"`, c.term))
+		}
+		terms[i] = "(" + strings.Join(alts, " OR ") + ")"
+	}
+	return strings.Join(terms, " AND "), nil
+}
+
+// editBudget is how many typos a token may contain: none for short words
+// and numbers, where a single edit changes the meaning.
+func editBudget(tok string) int {
+	if strings.IndexFunc(tok, unicode.IsDigit) >= 0 {
+		return 0
+	}
+	switch n := len([]rune(tok)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// searchTokens lower-cases text and splits it into letters-and-digits
+// words, matching how the unicode61 tokenizer indexes them. Stripping
+// everything else also keeps user input from being read as FTS5 syntax.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// editDistance is the Damerau–Levenshtein (optimal string alignment)
+// distance, so a swapped pair of letters counts as one typo.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
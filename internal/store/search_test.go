@@ -0,0 +1,126 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func searchStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "53009", RoadName: "Bishan Rd", Description: "Bishan Int"},
+		{BusStopCode: "53241", RoadName: "Bishan St 13", Description: "Opp Blk 123"},
+		{BusStopCode: "53239", RoadName: "Bishan St 13", Description: "Blk 123"},
+		{BusStopCode: "01012", RoadName: "Victoria St", Description: "Hotel Grand Pacific"},
+		{BusStopCode: "01013", RoadName: "Victoria St", Description: "St. Joseph's Ch"},
+		{BusStopCode: "40009", RoadName: "Bukit Timah Rd", Description: "Aft Newton Stn"},
+	})
+	return s
+}
+
+func stopCodes(stops []Stop) []string {
+	codes := make([]string, len(stops))
+	for i, st := range stops {
+		codes[i] = st.Code
+	}
+	return codes
+}
+
+func TestSearchStops(t *testing.T) {
+	s := searchStore(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"code", "53009", []string{"53009"}},
+		{"code prefix", "0101", []string{"01012", "01013"}},
+		{"road name", "victoria", []string{"01012", "01013"}},
+		{"word prefixes", "vic hot", []string{"01012"}},
+		{"abbreviation as typed", "opp blk 123", []string{"53241"}},
+		{"expanded abbreviation", "opposite block", []string{"53241"}},
+		{"abbreviated query", "newton station", []string{"40009"}},
+		{"interchange", "bishan interchange", []string{"53009"}},
+		{"typo", "vitcoria", []string{"01012", "01013"}},
+		{"typo with prefix", "grnad pacific", []string{"01012"}},
+		{"punctuation", "st. joseph's", []string{"01013"}},
+		{"syntax is literal", `"OR" NEAR(`, nil},
+		{"no match", "zzzzzz", nil},
+		{"empty", "  ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.SearchStops(tt.query, 10)
+			if err != nil {
+				t.Fatalf("SearchStops(%q) error: %v", tt.query, err)
+			}
+			codes := stopCodes(got)
+			if len(codes) != len(tt.want) {
+				t.Fatalf("SearchStops(%q) = %v, want %v", tt.query, codes, tt.want)
+			}
+			for _, c := range tt.want {
+				found := false
+				for _, g := range codes {
+					found = found || g == c
+				}
+				if !found {
+					t.Errorf("SearchStops(%q) = %v, want %v", tt.query, codes, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchStopsExactCodeFirst(t *testing.T) {
+	s := searchStore(t)
+
+	// "123" matches both Blk 123 stops by description; neither is a code,
+	// but a code search must put that stop first.
+	got, err := s.SearchStops("53239", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || got[0].Code != "53239" {
+		t.Errorf("expected 53239 first, got %v", stopCodes(got))
+	}
+}
+
+func TestSearchStopsFollowsSync(t *testing.T) {
+	s := searchStore(t)
+
+	publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "01012", RoadName: "Victoria St", Description: "Raffles Hotel"},
+	})
+
+	if got, _ := s.SearchStops("grand pacific", 10); len(got) != 0 {
+		t.Errorf("expected the old description to be gone, got %v", stopCodes(got))
+	}
+	if got, _ := s.SearchStops("raffles", 10); len(got) != 1 {
+		t.Errorf("expected the new description to be indexed, got %v", stopCodes(got))
+	}
+}
+
+func TestNewIndexesExistingStops(t *testing.T) {
+	// A database synced before the index existed.
+	s, err := New(baselineDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	got, err := s.SearchStops("bishan", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected existing stops to be indexed on open, got %v", stopCodes(got))
+	}
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// ServiceDirection is one direction of a bus service as published in the
+// DataMall BusServices dataset.
+type ServiceDirection struct {
+	ServiceNo       string `json:"serviceNo"`
+	Direction       int    `json:"direction"`
+	Operator        string `json:"operator"`
+	Category        string `json:"category"`
+	OriginCode      string `json:"originCode"`
+	DestinationCode string `json:"destinationCode"`
+	AMPeakFreq      string `json:"amPeakFreq"`
+	AMOffpeakFreq   string `json:"amOffpeakFreq"`
+	PMPeakFreq      string `json:"pmPeakFreq"`
+	PMOffpeakFreq   string `json:"pmOffpeakFreq"`
+	LoopDesc        string `json:"loopDesc"`
+}
+
+// syncServices replaces the service directions table and records each
+// service's operator in bus_services.
+func syncServices(db *sql.DB, services []lta.BusService) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM bus_service_directions`); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO bus_service_directions
+		(service_no, direction, operator, category, origin_code, destination_code,
+		 am_peak_freq, am_offpeak_freq, pm_peak_freq, pm_offpeak_freq, loop_desc)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	opStmt, err := tx.Prepare(`INSERT INTO bus_services (service_no, operator) VALUES (?, ?)
+		ON CONFLICT(service_no) DO UPDATE SET operator = excluded.operator`)
+	if err != nil {
+		return err
+	}
+	defer opStmt.Close()
+
+	for _, sv := range services {
+		if _, err := stmt.Exec(sv.ServiceNo, sv.Direction, sv.Operator, sv.Category, sv.OriginCode, sv.DestinationCode,
+			sv.AMPeakFreq, sv.AMOffpeakFreq, sv.PMPeakFreq, sv.PMOffpeakFreq, sv.LoopDesc); err != nil {
+			return err
+		}
+		if sv.Operator == "" {
+			continue
+		}
+		if _, err := opStmt.Exec(sv.ServiceNo, sv.Operator); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetServiceDirections returns the BusServices entries for a service,
+// ordered by direction.
+func (s *Store) GetServiceDirections(serviceNo string) ([]ServiceDirection, error) {
+	rows, err := s.data().Query(`
+		SELECT service_no, direction, operator, category, origin_code, destination_code,
+		       am_peak_freq, am_offpeak_freq, pm_peak_freq, pm_offpeak_freq, loop_desc
+		FROM bus_service_directions
+		WHERE service_no = ?
+		ORDER BY direction
+	`, serviceNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ServiceDirection
+	for rows.Next() {
+		var d ServiceDirection
+		if err := rows.Scan(&d.ServiceNo, &d.Direction, &d.Operator, &d.Category, &d.OriginCode, &d.DestinationCode,
+			&d.AMPeakFreq, &d.AMOffpeakFreq, &d.PMPeakFreq, &d.PMOffpeakFreq, &d.LoopDesc); err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// ServicesAtStops returns the services calling at each of the given stops,
+// keyed by stop code. Stops no route calls at are absent from the map.
+func (s *Store) ServicesAtStops(codes []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	if len(codes) == 0 {
+		return result, nil
+	}
+
+	args := make([]any, len(codes))
+	for i, c := range codes {
+		args[i] = c
+	}
+	rows, err := s.data().Query(`
+		SELECT DISTINCT bus_stop_code, service_no
+		FROM bus_routes
+		WHERE bus_stop_code IN (?`+strings.Repeat(", ?", len(codes)-1)+`)
+		ORDER BY bus_stop_code, service_no
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code, serviceNo string
+		if err := rows.Scan(&code, &serviceNo); err != nil {
+			return nil, err
+		}
+		result[code] = append(result[code], serviceNo)
+	}
+	return result, rows.Err()
+}
+
+// StopService is a service calling at a stop: which direction, where it is
+// in the route, and where it is heading. DestinationName is the
+// destination stop's description, or empty if that stop is unknown.
+type StopService struct {
+	ServiceNo       string `json:"serviceNo"`
+	Operator        string `json:"operator"`
+	Direction       int    `json:"direction"`
+	Sequence        int    `json:"sequence"`
+	DestinationCode string `json:"destinationCode"`
+	DestinationName string `json:"destinationName"`
+}
+
+// GetServicesAtStop returns every service calling at a stop from
+// bus_routes, ordered by service, direction and sequence. A service that
+// calls twice in one direction (e.g. a loop) appears once per visit.
+// Destinations come from BusServices, falling back to the last stop of the
+// route when a service is missing there.
+func (s *Store) GetServicesAtStop(code string) ([]StopService, error) {
+	rows, err := s.data().Query(`
+		SELECT x.service_no, x.operator, x.direction, x.stop_sequence, x.dest, COALESCE(ds.description, '')
+		FROM (
+			SELECT r.service_no, COALESCE(sv.operator, '') AS operator, r.direction, r.stop_sequence,
+			       COALESCE(NULLIF(d.destination_code, ''), (
+			           SELECT last.bus_stop_code FROM bus_routes last
+			           WHERE last.service_no = r.service_no AND last.direction = r.direction
+			           ORDER BY last.stop_sequence DESC LIMIT 1
+			       )) AS dest
+			FROM bus_routes r
+			LEFT JOIN bus_services sv ON sv.service_no = r.service_no
+			LEFT JOIN bus_service_directions d ON d.service_no = r.service_no AND d.direction = r.direction
+			WHERE r.bus_stop_code = ?
+		) x
+		LEFT JOIN bus_stops ds ON ds.code = x.dest
+		ORDER BY x.service_no, x.direction, x.stop_sequence
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []StopService
+	for rows.Next() {
+		var ss StopService
+		if err := rows.Scan(&ss.ServiceNo, &ss.Operator, &ss.Direction, &ss.Sequence, &ss.DestinationCode, &ss.DestinationName); err != nil {
+			return nil, err
+		}
+		results = append(results, ss)
+	}
+	return results, rows.Err()
+}
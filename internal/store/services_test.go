@@ -0,0 +1,131 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func TestSyncServices(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	services := []lta.BusService{
+		{ServiceNo: "118", Operator: "GAS", Direction: 1, Category: "TRUNK", OriginCode: "65009", DestinationCode: "97009", AMPeakFreq: "05-08", PMOffpeakFreq: "10-13"},
+		{ServiceNo: "118", Operator: "GAS", Direction: 2, Category: "TRUNK", OriginCode: "97009", DestinationCode: "65009"},
+		{ServiceNo: "225G", Operator: "SBST", Direction: 1, Category: "FEEDER", OriginCode: "84009", DestinationCode: "84009", LoopDesc: "Bedok Nth Ave 3"},
+	}
+	publishServices(t, s, services)
+
+	dirs, err := s.GetServiceDirections("118")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 {
+		t.Fatalf("expected 2 directions, got %d", len(dirs))
+	}
+	if dirs[0].Direction != 1 || dirs[0].AMPeakFreq != "05-08" || dirs[0].PMOffpeakFreq != "10-13" {
+		t.Errorf("unexpected direction 1: %+v", dirs[0])
+	}
+
+	op, err := s.GetServiceOperator("225G")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != "SBST" {
+		t.Errorf("expected operator SBST, got %q", op)
+	}
+
+	// Re-sync replaces rather than appends.
+	publishServices(t, s, services[2:])
+	dirs, _ = s.GetServiceDirections("118")
+	if len(dirs) != 0 {
+		t.Errorf("expected 118 directions removed, got %d", len(dirs))
+	}
+}
+
+func TestServicesAtStops(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishRoutes(t, s, []lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "10", Direction: 2, StopSequence: 5, BusStopCode: "A"},
+		{ServiceNo: "196", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "196", Direction: 1, StopSequence: 2, BusStopCode: "B"},
+		{ServiceNo: "5", Direction: 1, StopSequence: 1, BusStopCode: "C"},
+	})
+
+	got, err := s.ServicesAtStops([]string{"A", "B", "Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 stops, got %v", got)
+	}
+	if a := got["A"]; len(a) != 2 || a[0] != "10" || a[1] != "196" {
+		t.Errorf("expected [10 196] at A, got %v", a)
+	}
+	if b := got["B"]; len(b) != 1 || b[0] != "196" {
+		t.Errorf("expected [196] at B, got %v", b)
+	}
+}
+
+func TestGetServicesAtStop(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "A", Description: "Stop A"},
+		{BusStopCode: "B", Description: "Stop B"},
+		{BusStopCode: "INT", Description: "Bishan Int"},
+	})
+	publishRoutes(t, s, []lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "B"},
+		{ServiceNo: "10", Direction: 2, StopSequence: 1, BusStopCode: "B"},
+		{ServiceNo: "10", Direction: 2, StopSequence: 2, BusStopCode: "A"},
+		// 20 isn't in BusServices; its destination is its last stop.
+		{ServiceNo: "20", Direction: 1, StopSequence: 1, BusStopCode: "A"},
+		{ServiceNo: "20", Direction: 1, StopSequence: 2, BusStopCode: "INT"},
+	})
+	publishServices(t, s, []lta.BusService{
+		{ServiceNo: "10", Direction: 1, Operator: "SBST", DestinationCode: "INT"},
+		{ServiceNo: "10", Direction: 2, Operator: "SBST", DestinationCode: "A"},
+	})
+
+	got, err := s.GetServicesAtStop("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StopService{
+		{ServiceNo: "10", Operator: "SBST", Direction: 1, Sequence: 1, DestinationCode: "INT", DestinationName: "Bishan Int"},
+		{ServiceNo: "10", Operator: "SBST", Direction: 2, Sequence: 2, DestinationCode: "A", DestinationName: "Stop A"},
+		{ServiceNo: "20", Operator: "", Direction: 1, Sequence: 1, DestinationCode: "INT", DestinationName: "Bishan Int"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d services, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("services[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	none, err := s.GetServicesAtStop("ZZZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Errorf("expected no services at an unknown stop, got %+v", none)
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+)
+
+// DefaultNearbyRadius is the search radius, in meters, used by Nearby.
+const DefaultNearbyRadius = 3000.0
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111_320.0
+
+// stopSpatialSchema creates an R*Tree over stop coordinates, keyed by the
+// bus_stops rowid. Triggers keep it in step with every write to bus_stops,
+// including the upserts in Sync.
+const stopSpatialSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS bus_stops_rtree USING rtree(id, min_lat, max_lat, min_lng, max_lng);
+	CREATE TRIGGER IF NOT EXISTS bus_stops_rtree_insert AFTER INSERT ON bus_stops BEGIN
+		INSERT OR REPLACE INTO bus_stops_rtree VALUES (new.rowid, new.latitude, new.latitude, new.longitude, new.longitude);
+	END;
+	CREATE TRIGGER IF NOT EXISTS bus_stops_rtree_update AFTER UPDATE OF latitude, longitude ON bus_stops BEGIN
+		UPDATE bus_stops_rtree
+		SET min_lat = new.latitude, max_lat = new.latitude, min_lng = new.longitude, max_lng = new.longitude
+		WHERE id = new.rowid;
+	END;
+	CREATE TRIGGER IF NOT EXISTS bus_stops_rtree_delete AFTER DELETE ON bus_stops BEGIN
+		DELETE FROM bus_stops_rtree WHERE id = old.rowid;
+	END;
+`
+
+// rebuildStopSpatial replaces the R*Tree's contents with the current
+// bus_stops.
+func rebuildStopSpatial(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM bus_stops_rtree`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO bus_stops_rtree
+		SELECT rowid, latitude, latitude, longitude, longitude FROM bus_stops
+	`)
+	return err
+}
+
+// NearbyWithin returns stops in service within radius meters of a point, closest
+// first. A limit of 0 returns them all.
+func (s *Store) NearbyWithin(lat, lng, radius float64, limit int) ([]StopWithDistance, error) {
+	dlat := radius / metersPerDegree
+	dlng := dlat / math.Cos(lat*math.Pi/180)
+
+	rows, err := s.data().Query(`
+		SELECT s.code, s.road_name, s.description, s.latitude, s.longitude
+		FROM bus_stops_rtree r
+		JOIN bus_stops s ON s.rowid = r.id
+		WHERE r.max_lat >= ? AND r.min_lat <= ?
+		  AND r.max_lng >= ? AND r.min_lng <= ?
+		  AND s.retired_at IS NULL
+	`, lat-dlat, lat+dlat, lng-dlng, lng+dlng)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []StopWithDistance
+	for rows.Next() {
+		var swd StopWithDistance
+		if err := rows.Scan(&swd.Code, &swd.RoadName, &swd.Description, &swd.Latitude, &swd.Longitude); err != nil {
+			return nil, err
+		}
+		swd.Distance = haversine(lat, lng, swd.Latitude, swd.Longitude)
+		if swd.Distance <= radius {
+			results = append(results, swd)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func TestNearbyWithin(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "A", Latitude: 1.3000, Longitude: 103.8000},
+		{BusStopCode: "B", Latitude: 1.3030, Longitude: 103.8000}, // ~330 m north
+		{BusStopCode: "C", Latitude: 1.3060, Longitude: 103.8060}, // ~940 m, inside the 1 km box's corner
+		{BusStopCode: "D", Latitude: 1.3080, Longitude: 103.8080}, // ~1.26 km, also in the box
+	})
+
+	got, err := s.NearbyWithin(1.3, 103.8, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, st := range got {
+		codes = append(codes, st.Code)
+	}
+	if len(codes) != 3 || codes[0] != "A" || codes[1] != "B" || codes[2] != "C" {
+		t.Errorf("expected [A B C] within 1 km, got %v", codes)
+	}
+
+	got, err = s.NearbyWithin(1.3, 103.8, 500, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Code != "A" {
+		t.Errorf("expected only A with limit 1, got %+v", got)
+	}
+}
+
+func TestNearbyFollowsSync(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishStops(t, s, []lta.BusStop{{BusStopCode: "A", Latitude: 1.3, Longitude: 103.8}})
+	// The stop moves ~2 km away.
+	publishStops(t, s, []lta.BusStop{{BusStopCode: "A", Latitude: 1.318, Longitude: 103.8}})
+
+	if got, _ := s.NearbyWithin(1.3, 103.8, 500, 0); len(got) != 0 {
+		t.Errorf("expected the old position to be gone, got %+v", got)
+	}
+	if got, _ := s.NearbyWithin(1.318, 103.8, 500, 0); len(got) != 1 {
+		t.Errorf("expected the new position to be indexed, got %+v", got)
+	}
+
+	var n int
+	if err := s.data().QueryRow(`SELECT COUNT(*) FROM bus_stops_rtree`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 index entry, got %d", n)
+	}
+}
+
+func TestNewBuildsSpatialIndex(t *testing.T) {
+	// A database synced before the index existed.
+	s, err := New(baselineDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got, _ := s.Nearby(1.3508, 103.8484, 10); len(got) != 2 {
+		t.Errorf("expected existing stops to be indexed on open, got %+v", got)
+	}
+}
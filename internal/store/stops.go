@@ -4,14 +4,30 @@ import (
 	"database/sql"
 	"math"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aattwwss/yabatasg/internal/lta"
 	_ "modernc.org/sqlite"
 )
 
+// Store is the SQLite store. db is the main database: users, the sync log
+// and the dataset registry. LTA data is read from the live dataset, which
+// each sync replaces as a whole; see datasets.go.
 type Store struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+
+	mu   sync.Mutex // serializes dataset swaps
+	live atomic.Pointer[sql.DB]
+	prev *sql.DB
+	// retired is the dataset the last Publish dropped. It stays open until
+	// the next Publish so readers that loaded it just before the swap can
+	// finish.
+	retired *retiredDataset
+	// build is the dataset a sync is writing, if any.
+	build *DatasetBuild
 }
 
 type StopWithDistance struct {
@@ -24,53 +40,38 @@ type StopWithDistance struct {
 }
 
 func New(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := openDB(dbPath, false)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(1)
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS bus_stops (
-			code        TEXT PRIMARY KEY,
-			road_name   TEXT NOT NULL,
-			description TEXT NOT NULL,
-			latitude    REAL NOT NULL,
-			longitude   REAL NOT NULL
-		);
-		CREATE TABLE IF NOT EXISTS meta (
-			key   TEXT PRIMARY KEY,
-			value TEXT NOT NULL
-		);
-		CREATE TABLE IF NOT EXISTS bus_routes (
-			service_no   TEXT NOT NULL,
-			direction    INTEGER NOT NULL,
-			stop_sequence INTEGER NOT NULL,
-			bus_stop_code TEXT NOT NULL,
-			distance     REAL NOT NULL,
-			PRIMARY KEY (service_no, direction, stop_sequence)
-		);
-		CREATE INDEX IF NOT EXISTS idx_bus_routes_service ON bus_routes(service_no);
-		CREATE TABLE IF NOT EXISTS bus_services (
-			service_no TEXT PRIMARY KEY,
-			operator   TEXT NOT NULL
-		);
-		CREATE TABLE IF NOT EXISTS users (
-			id         TEXT PRIMARY KEY,
-			phrase     TEXT UNIQUE NOT NULL,
-			token      TEXT UNIQUE NOT NULL,
-			config     TEXT NOT NULL DEFAULT '[]',
-			created_at TEXT NOT NULL,
-			updated_at TEXT NOT NULL
-		);
-		CREATE INDEX IF NOT EXISTS idx_users_phrase ON users(phrase);
-		CREATE INDEX IF NOT EXISTS idx_users_token  ON users(token);
-	`)
+	s := &Store{db: db, path: dbPath}
+	if err := s.openDatasets(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openDB opens a SQLite database and brings its schema up to date. A
+// dataset file gets only the LTA data tables; see datasets.go.
+func openDB(path string, dataset bool) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db, dataset); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	return &Store{db: db}, nil
+// data returns the live dataset.
+func (s *Store) data() *sql.DB {
+	return s.live.Load()
 }
 
 type Stop struct {
@@ -79,101 +80,181 @@ type Stop struct {
 	Description string  `json:"description"`
 	Latitude    float64 `json:"latitude"`
 	Longitude   float64 `json:"longitude"`
+	// RetiredAt is when the stop disappeared from LTA's data; nil while it
+	// is in service.
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
 }
 
+// GetStop returns the stop with code, including a retired one, or nil if
+// there is none.
 func (s *Store) GetStop(code string) (*Stop, error) {
 	var stop Stop
-	err := s.db.QueryRow(
-		`SELECT code, road_name, description, latitude, longitude FROM bus_stops WHERE code = ?`, code,
-	).Scan(&stop.Code, &stop.RoadName, &stop.Description, &stop.Latitude, &stop.Longitude)
+	var retiredAt sql.NullString
+	err := s.data().QueryRow(
+		`SELECT code, road_name, description, latitude, longitude, retired_at FROM bus_stops WHERE code = ?`, code,
+	).Scan(&stop.Code, &stop.RoadName, &stop.Description, &stop.Latitude, &stop.Longitude, &retiredAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	if retiredAt.Valid {
+		t, err := time.Parse(time.RFC3339, retiredAt.String)
+		if err != nil {
+			return nil, err
+		}
+		stop.RetiredAt = &t
+	}
 	return &stop, nil
 }
 
-func (s *Store) Sync(stops []lta.BusStop) error {
-	tx, err := s.db.Begin()
+// StopDiff is what a Sync changed, by stop code. Added includes retired
+// stops that reappeared; a stop that moved and was renamed is in both
+// Moved and Renamed; Removed stops are retired rather than deleted.
+type StopDiff struct {
+	Added   []string `json:"added"`
+	Moved   []string `json:"moved"`
+	Renamed []string `json:"renamed"`
+	Removed []string `json:"removed"`
+}
+
+// Changes lists the diff as change log entries.
+func (d StopDiff) Changes() []Change {
+	var changes []Change
+	for _, c := range []struct {
+		kind  string
+		codes []string
+	}{
+		{ChangeStopAdded, d.Added},
+		{ChangeStopMoved, d.Moved},
+		{ChangeStopRenamed, d.Renamed},
+		{ChangeStopRemoved, d.Removed},
+	} {
+		for _, code := range c.codes {
+			changes = append(changes, Change{Kind: c.kind, StopCode: code})
+		}
+	}
+	return changes
+}
+
+type knownStop struct {
+	stop    lta.BusStop
+	retired bool
+}
+
+// syncStops upserts stops and retires any active stop missing from them, so
+// links to a decommissioned stop can still be answered.
+func syncStops(db *sql.DB, stops []lta.BusStop) (StopDiff, error) {
+	var diff StopDiff
+	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return diff, err
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO bus_stops (code, road_name, description, latitude, longitude) VALUES (?, ?, ?, ?, ?)`)
+	known, err := knownStops(tx)
 	if err != nil {
-		return err
+		return diff, err
+	}
+
+	// An upsert rather than INSERT OR REPLACE keeps each stop's rowid, which
+	// the spatial index is keyed by.
+	stmt, err := tx.Prepare(`INSERT INTO bus_stops (code, road_name, description, latitude, longitude) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(code) DO UPDATE SET road_name = excluded.road_name, description = excluded.description,
+			latitude = excluded.latitude, longitude = excluded.longitude, retired_at = NULL`)
+	if err != nil {
+		return diff, err
 	}
 	defer stmt.Close()
 
+	seen := make(map[string]bool, len(stops))
 	for _, st := range stops {
+		if seen[st.BusStopCode] {
+			continue
+		}
+		seen[st.BusStopCode] = true
+		if old, ok := known[st.BusStopCode]; !ok || old.retired {
+			diff.Added = append(diff.Added, st.BusStopCode)
+		} else {
+			if old.stop.Latitude != st.Latitude || old.stop.Longitude != st.Longitude {
+				diff.Moved = append(diff.Moved, st.BusStopCode)
+			}
+			if old.stop.Description != st.Description || old.stop.RoadName != st.RoadName {
+				diff.Renamed = append(diff.Renamed, st.BusStopCode)
+			}
+		}
+
 		_, err = stmt.Exec(st.BusStopCode, st.RoadName, st.Description, st.Latitude, st.Longitude)
 		if err != nil {
-			return err
+			return diff, err
 		}
 	}
 
-	_, err = tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('last_synced', ?)`, time.Now().UTC().Format(time.RFC3339))
-	if err != nil {
-		return err
+	now := time.Now().UTC().Format(time.RFC3339)
+	for code, old := range known {
+		if old.retired || seen[code] {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE bus_stops SET retired_at = ? WHERE code = ?`, now, code); err != nil {
+			return diff, err
+		}
+		diff.Removed = append(diff.Removed, code)
 	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Moved)
+	sort.Strings(diff.Renamed)
+	sort.Strings(diff.Removed)
 
-	return tx.Commit()
-}
-
-func (s *Store) LastSynced() (time.Time, error) {
-	var val string
-	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = 'last_synced'`).Scan(&val)
-	if err == sql.ErrNoRows {
-		return time.Time{}, nil
+	if err := rebuildStopSearch(tx); err != nil {
+		return diff, err
 	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('last_synced', ?)`, now)
 	if err != nil {
-		return time.Time{}, err
+		return diff, err
 	}
-	return time.Parse(time.RFC3339, val)
+
+	return diff, tx.Commit()
 }
 
-func (s *Store) Nearby(lat, lng float64, limit int) ([]StopWithDistance, error) {
-	// bounding box: ~3 km radius (~0.027 degrees)
-	dlat := 0.027
-	dlng := 0.027 / math.Cos(lat*math.Pi/180)
-
-	rows, err := s.db.Query(`
-		SELECT code, road_name, description, latitude, longitude
-		FROM bus_stops
-		WHERE latitude  BETWEEN ? AND ?
-		  AND longitude BETWEEN ? AND ?
-	`, lat-dlat, lat+dlat, lng-dlng, lng+dlng)
+func knownStops(tx *sql.Tx) (map[string]knownStop, error) {
+	rows, err := tx.Query(`SELECT code, road_name, description, latitude, longitude, retired_at IS NOT NULL FROM bus_stops`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []StopWithDistance
+	known := make(map[string]knownStop)
 	for rows.Next() {
-		var swd StopWithDistance
-		if err := rows.Scan(&swd.Code, &swd.RoadName, &swd.Description, &swd.Latitude, &swd.Longitude); err != nil {
+		var k knownStop
+		if err := rows.Scan(&k.stop.BusStopCode, &k.stop.RoadName, &k.stop.Description, &k.stop.Latitude, &k.stop.Longitude, &k.retired); err != nil {
 			return nil, err
 		}
-		swd.Distance = haversine(lat, lng, swd.Latitude, swd.Longitude)
-		results = append(results, swd)
+		known[k.stop.BusStopCode] = k
 	}
+	return known, rows.Err()
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Distance < results[j].Distance
-	})
-
-	if limit > 0 && limit < len(results) {
-		results = results[:limit]
+func (s *Store) LastSynced() (time.Time, error) {
+	var val string
+	err := s.data().QueryRow(`SELECT value FROM meta WHERE key = 'last_synced'`).Scan(&val)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
 	}
+	return time.Parse(time.RFC3339, val)
+}
 
-	return results, nil
+// Nearby returns up to limit stops within DefaultNearbyRadius, closest first.
+func (s *Store) Nearby(lat, lng float64, limit int) ([]StopWithDistance, error) {
+	return s.NearbyWithin(lat, lng, DefaultNearbyRadius, limit)
 }
 
 func (s *Store) GetAllStopCodes() ([]string, error) {
-	rows, err := s.db.Query(`SELECT code FROM bus_stops ORDER BY code`)
+	rows, err := s.data().Query(`SELECT code FROM bus_stops WHERE retired_at IS NULL ORDER BY code`)
 	if err != nil {
 		return nil, err
 	}
@@ -191,7 +272,44 @@ func (s *Store) GetAllStopCodes() ([]string, error) {
 }
 
 func (s *Store) GetAllServiceNumbers() ([]string, error) {
-	rows, err := s.db.Query(`SELECT DISTINCT service_no FROM bus_services ORDER BY service_no`)
+	rows, err := s.data().Query(`SELECT DISTINCT service_no FROM bus_services ORDER BY service_no`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var svcs []string
+	for rows.Next() {
+		var no string
+		if err := rows.Scan(&no); err != nil {
+			return nil, err
+		}
+		svcs = append(svcs, no)
+	}
+	return svcs, rows.Err()
+}
+
+// DatasetSize counts the stored LTA data: stops in service, route rows and
+// service directions.
+type DatasetSize struct {
+	Stops    int
+	Routes   int
+	Services int
+}
+
+func (s *Store) DatasetSize() (DatasetSize, error) {
+	var n DatasetSize
+	err := s.data().QueryRow(`SELECT
+		(SELECT COUNT(*) FROM bus_stops WHERE retired_at IS NULL),
+		(SELECT COUNT(*) FROM bus_routes),
+		(SELECT COUNT(*) FROM bus_service_directions)
+	`).Scan(&n.Stops, &n.Routes, &n.Services)
+	return n, err
+}
+
+// RoutedServices returns the services with at least one stop in bus_routes.
+func (s *Store) RoutedServices() ([]string, error) {
+	rows, err := s.data().Query(`SELECT DISTINCT service_no FROM bus_routes ORDER BY service_no`)
 	if err != nil {
 		return nil, err
 	}
@@ -216,6 +334,26 @@ type ServiceStop struct {
 	Sequence    int     `json:"sequence"`
 	Latitude    float64 `json:"latitude"`
 	Longitude   float64 `json:"longitude"`
+	BusTimes
+}
+
+// BusTimes holds the first and last bus of a service at one stop, as
+// published in BusRoutes: "HHmm" Singapore time, or "-" / "" when the
+// service doesn't run that day.
+type BusTimes struct {
+	WDFirstBus  string `json:"wdFirstBus"`
+	WDLastBus   string `json:"wdLastBus"`
+	SATFirstBus string `json:"satFirstBus"`
+	SATLastBus  string `json:"satLastBus"`
+	SUNFirstBus string `json:"sunFirstBus"`
+	SUNLastBus  string `json:"sunLastBus"`
+}
+
+// StopServiceTimes is the first/last bus of one service at a stop.
+type StopServiceTimes struct {
+	ServiceNo string `json:"serviceNo"`
+	Direction int    `json:"direction"`
+	BusTimes
 }
 
 type ServiceSearchResult struct {
@@ -224,7 +362,7 @@ type ServiceSearchResult struct {
 }
 
 func (s *Store) SearchServices(query string) ([]ServiceSearchResult, error) {
-	rows, err := s.db.Query(
+	rows, err := s.data().Query(
 		`SELECT service_no, operator FROM bus_services WHERE service_no LIKE ? ORDER BY service_no`,
 		query+"%",
 	)
@@ -246,7 +384,7 @@ func (s *Store) SearchServices(query string) ([]ServiceSearchResult, error) {
 
 func (s *Store) GetServiceOperator(serviceNo string) (string, error) {
 	var operator string
-	err := s.db.QueryRow(`SELECT operator FROM bus_services WHERE service_no = ?`, serviceNo).Scan(&operator)
+	err := s.data().QueryRow(`SELECT operator FROM bus_services WHERE service_no = ?`, serviceNo).Scan(&operator)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
@@ -256,139 +394,153 @@ func (s *Store) GetServiceOperator(serviceNo string) (string, error) {
 	return operator, nil
 }
 
+// UpsertServiceOperator records an operator learned from arrivals. It also
+// goes into the dataset a sync is building, which copied the live data
+// before the operator was learned and would otherwise drop it on Publish.
 func (s *Store) UpsertServiceOperator(serviceNo, operator string) error {
-	_, err := s.db.Exec(
-		`INSERT OR REPLACE INTO bus_services (service_no, operator) VALUES (?, ?)`,
-		serviceNo, operator,
-	)
-	return err
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dbs := []*sql.DB{s.data()}
+	if s.build != nil {
+		dbs = append(dbs, s.build.db)
+	}
+	for _, db := range dbs {
+		if _, err := db.Exec(
+			`INSERT OR REPLACE INTO bus_services (service_no, operator) VALUES (?, ?)`,
+			serviceNo, operator,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *Store) SeedServiceOperators() error {
-	_, err := s.db.Exec(
+func seedServiceOperators(db *sql.DB) error {
+	_, err := db.Exec(
 		`INSERT OR IGNORE INTO bus_services (service_no, operator)
 		 SELECT DISTINCT service_no, '' FROM bus_routes`,
 	)
 	return err
 }
 
-type ServiceStopRef struct {
-	ServiceNo string
-	StopCode  string
-}
-
-func (s *Store) DistinctServiceStops() ([]ServiceStopRef, error) {
-	rows, err := s.db.Query(
-		`SELECT r.service_no, r.bus_stop_code FROM bus_routes r
-		 WHERE r.stop_sequence = 1 AND r.direction = 1`,
-	)
+func (s *Store) GetStopsByService(serviceNo string) ([]ServiceStop, error) {
+	rows, err := s.data().Query(`
+		SELECT r.bus_stop_code, COALESCE(s.road_name, ''), COALESCE(s.description, ''), r.direction, r.stop_sequence, COALESCE(s.latitude, 0), COALESCE(s.longitude, 0),
+		       r.wd_first_bus, r.wd_last_bus, r.sat_first_bus, r.sat_last_bus, r.sun_first_bus, r.sun_last_bus
+		FROM bus_routes r
+		LEFT JOIN bus_stops s ON r.bus_stop_code = s.code
+		WHERE r.service_no = ?
+		ORDER BY r.direction, r.stop_sequence
+	`, serviceNo)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var refs []ServiceStopRef
+	var results []ServiceStop
 	for rows.Next() {
-		var r ServiceStopRef
-		if err := rows.Scan(&r.ServiceNo, &r.StopCode); err != nil {
+		var st ServiceStop
+		if err := rows.Scan(&st.StopCode, &st.RoadName, &st.Description, &st.Direction, &st.Sequence, &st.Latitude, &st.Longitude,
+			&st.WDFirstBus, &st.WDLastBus, &st.SATFirstBus, &st.SATLastBus, &st.SUNFirstBus, &st.SUNLastBus); err != nil {
 			return nil, err
 		}
-		refs = append(refs, r)
+		results = append(results, st)
 	}
-	return refs, rows.Err()
+	return results, rows.Err()
 }
 
-func (s *Store) MissingOperatorServices() ([]string, error) {
-	rows, err := s.db.Query(`SELECT service_no FROM bus_services WHERE operator = ''`)
+// GetStopServiceTimes returns the first/last bus times of every service
+// calling at a stop. A service that calls twice (e.g. a loop) is reported
+// once, for its earliest visit.
+func (s *Store) GetStopServiceTimes(stopCode string) ([]StopServiceTimes, error) {
+	rows, err := s.data().Query(`
+		SELECT service_no, direction, wd_first_bus, wd_last_bus, sat_first_bus, sat_last_bus, sun_first_bus, sun_last_bus
+		FROM bus_routes
+		WHERE bus_stop_code = ?
+		ORDER BY service_no, direction, stop_sequence
+	`, stopCode)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var svcs []string
+	var results []StopServiceTimes
+	seen := make(map[string]bool)
 	for rows.Next() {
-		var no string
-		if err := rows.Scan(&no); err != nil {
+		var st StopServiceTimes
+		if err := rows.Scan(&st.ServiceNo, &st.Direction,
+			&st.WDFirstBus, &st.WDLastBus, &st.SATFirstBus, &st.SATLastBus, &st.SUNFirstBus, &st.SUNLastBus); err != nil {
 			return nil, err
 		}
-		svcs = append(svcs, no)
+		if seen[st.ServiceNo] {
+			continue
+		}
+		seen[st.ServiceNo] = true
+		results = append(results, st)
 	}
-	return svcs, rows.Err()
+	return results, rows.Err()
 }
 
-func (s *Store) AlternateServiceStops() ([]ServiceStopRef, error) {
-	rows, err := s.db.Query(
-		`SELECT r.service_no, r.bus_stop_code FROM bus_routes r
-		 JOIN bus_services bs ON r.service_no = bs.service_no
-		 WHERE bs.operator = '' AND r.stop_sequence = 1 AND r.direction = 2`,
-	)
+// syncRoutes replaces all bus routes and returns how each service's routes
+// differ from the ones replaced.
+func syncRoutes(db *sql.DB, routes []lta.BusRoute) ([]Change, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var refs []ServiceStopRef
-	for rows.Next() {
-		var r ServiceStopRef
-		if err := rows.Scan(&r.ServiceNo, &r.StopCode); err != nil {
-			return nil, err
-		}
-		refs = append(refs, r)
+	before, err := routeSequences(tx)
+	if err != nil {
+		return nil, err
 	}
-	return refs, rows.Err()
-}
 
-func (s *Store) GetStopsByService(serviceNo string) ([]ServiceStop, error) {
-	rows, err := s.db.Query(`
-		SELECT r.bus_stop_code, COALESCE(s.road_name, ''), COALESCE(s.description, ''), r.direction, r.stop_sequence, COALESCE(s.latitude, 0), COALESCE(s.longitude, 0)
-		FROM bus_routes r
-		LEFT JOIN bus_stops s ON r.bus_stop_code = s.code
-		WHERE r.service_no = ?
-		ORDER BY r.direction, r.stop_sequence
-	`, serviceNo)
+	if _, err := tx.Exec(`DELETE FROM bus_routes`); err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO bus_routes
+		(service_no, direction, stop_sequence, bus_stop_code, distance,
+		 wd_first_bus, wd_last_bus, sat_first_bus, sat_last_bus, sun_first_bus, sun_last_bus)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer stmt.Close()
 
-	var results []ServiceStop
-	for rows.Next() {
-		var st ServiceStop
-		if err := rows.Scan(&st.StopCode, &st.RoadName, &st.Description, &st.Direction, &st.Sequence, &st.Latitude, &st.Longitude); err != nil {
+	for _, r := range routes {
+		if _, err := stmt.Exec(r.ServiceNo, r.Direction, r.StopSequence, r.BusStopCode, r.Distance,
+			r.WDFirstBus, r.WDLastBus, r.SATFirstBus, r.SATLastBus, r.SUNFirstBus, r.SUNLastBus); err != nil {
 			return nil, err
 		}
-		results = append(results, st)
 	}
-	return results, rows.Err()
-}
 
-func (s *Store) SyncRoutes(routes []lta.BusRoute) error {
-	tx, err := s.db.Begin()
+	after, err := routeSequences(tx)
 	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`DELETE FROM bus_routes`); err != nil {
-		return err
+		return nil, err
 	}
 
-	stmt, err := tx.Prepare(`INSERT INTO bus_routes (service_no, direction, stop_sequence, bus_stop_code, distance) VALUES (?, ?, ?, ?, ?)`)
+	_, err = tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('routes_synced', ?)`, time.Now().UTC().Format(time.RFC3339Nano))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
 
-	for _, r := range routes {
-		if _, err := stmt.Exec(r.ServiceNo, r.Direction, r.StopSequence, r.BusStopCode, r.Distance); err != nil {
-			return err
-		}
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
-
-	return tx.Commit()
+	return diffRoutes(before, after), nil
 }
 
 func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, db := range []*sql.DB{s.live.Load(), s.prev} {
+		if db != nil && db != s.db {
+			db.Close()
+		}
+	}
+	s.retired.close()
 	return s.db.Close()
 }
 
@@ -1,12 +1,57 @@
 package store
 
 import (
+	"database/sql"
 	"math"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/aattwwss/yabatasg/internal/lta"
 )
 
+// publishDataset applies sync to a new dataset build and publishes it, the
+// way the syncer does.
+func publishDataset(t *testing.T, s *Store, sync func(b *DatasetBuild) error) {
+	t.Helper()
+	b, err := s.BeginDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Discard()
+	if err := sync(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func publishStops(t *testing.T, s *Store, stops []lta.BusStop) StopDiff {
+	t.Helper()
+	var diff StopDiff
+	publishDataset(t, s, func(b *DatasetBuild) (err error) {
+		diff, err = b.Sync(stops)
+		return err
+	})
+	return diff
+}
+
+func publishRoutes(t *testing.T, s *Store, routes []lta.BusRoute) []Change {
+	t.Helper()
+	var changes []Change
+	publishDataset(t, s, func(b *DatasetBuild) (err error) {
+		changes, err = b.SyncRoutes(routes)
+		return err
+	})
+	return changes
+}
+
+func publishServices(t *testing.T, s *Store, services []lta.BusService) {
+	t.Helper()
+	publishDataset(t, s, func(b *DatasetBuild) error { return b.SyncServices(services) })
+}
+
 func TestHaversine(t *testing.T) {
 	// Same point → 0 distance
 	d := haversine(1.3, 103.8, 1.3, 103.8)
@@ -140,12 +185,8 @@ func TestSearchServices(t *testing.T) {
 		{ServiceNo: "51", Direction: 1, StopSequence: 1, BusStopCode: "S1", Distance: 0},
 		{ServiceNo: "188", Direction: 1, StopSequence: 1, BusStopCode: "S3", Distance: 0},
 	}
-	if err := s.SyncRoutes(routes); err != nil {
-		t.Fatalf("SyncRoutes failed: %v", err)
-	}
-	if err := s.SeedServiceOperators(); err != nil {
-		t.Fatalf("SeedServiceOperators failed: %v", err)
-	}
+	publishRoutes(t, s, routes)
+	publishDataset(t, s, (*DatasetBuild).SeedServiceOperators)
 
 	tests := []struct {
 		query string
@@ -188,9 +229,7 @@ func TestGetStopsByService(t *testing.T) {
 		{ServiceNo: "5", Direction: 1, StopSequence: 2, BusStopCode: "S2", Distance: 1.2},
 		{ServiceNo: "5", Direction: 2, StopSequence: 1, BusStopCode: "S2", Distance: 0},
 	}
-	if err := s.SyncRoutes(routes); err != nil {
-		t.Fatalf("SyncRoutes failed: %v", err)
-	}
+	publishRoutes(t, s, routes)
 
 	stops, err := s.GetStopsByService("5")
 	if err != nil {
@@ -229,9 +268,7 @@ func TestSyncRoutes(t *testing.T) {
 		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A1", Distance: 0},
 		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "A2", Distance: 1.5},
 	}
-	if err := s.SyncRoutes(routes); err != nil {
-		t.Fatalf("first SyncRoutes failed: %v", err)
-	}
+	publishRoutes(t, s, routes)
 
 	// Verify they exist
 	stops, _ := s.GetStopsByService("10")
@@ -243,9 +280,7 @@ func TestSyncRoutes(t *testing.T) {
 	newRoutes := []lta.BusRoute{
 		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "B1", Distance: 0},
 	}
-	if err := s.SyncRoutes(newRoutes); err != nil {
-		t.Fatalf("second SyncRoutes failed: %v", err)
-	}
+	publishRoutes(t, s, newRoutes)
 
 	stops, _ = s.GetStopsByService("10")
 	if len(stops) != 1 {
@@ -256,7 +291,141 @@ func TestSyncRoutes(t *testing.T) {
 	}
 }
 
+func TestSyncRoutesBusTimes(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	routes := []lta.BusRoute{
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A1", WDFirstBus: "0542", WDLastBus: "0030", SATFirstBus: "0600", SATLastBus: "2330", SUNFirstBus: "-", SUNLastBus: "-"},
+		{ServiceNo: "10", Direction: 1, StopSequence: 5, BusStopCode: "A1", WDFirstBus: "0610", WDLastBus: "0100"},
+		{ServiceNo: "14", Direction: 2, StopSequence: 3, BusStopCode: "A1", WDFirstBus: "0530", WDLastBus: "2345"},
+	}
+	publishRoutes(t, s, routes)
+
+	stops, err := s.GetStopsByService("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stops[0].WDFirstBus != "0542" || stops[0].SATLastBus != "2330" || stops[0].SUNFirstBus != "-" {
+		t.Errorf("unexpected bus times: %+v", stops[0].BusTimes)
+	}
+
+	times, err := s.GetStopServiceTimes("A1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 {
+		t.Fatalf("expected 2 services at A1, got %d", len(times))
+	}
+	if times[0].ServiceNo != "10" || times[0].WDFirstBus != "0542" {
+		t.Errorf("expected earliest visit of 10, got %+v", times[0])
+	}
+}
+
+func TestNewAddsBusTimeColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE bus_routes (
+		service_no TEXT NOT NULL, direction INTEGER NOT NULL, stop_sequence INTEGER NOT NULL,
+		bus_stop_code TEXT NOT NULL, distance REAL NOT NULL,
+		PRIMARY KEY (service_no, direction, stop_sequence));
+		INSERT INTO bus_routes VALUES ('10', 1, 1, 'A1', 0);`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to open old database: %v", err)
+	}
+	defer s.Close()
+
+	stops, err := s.GetStopsByService("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stops) != 1 || stops[0].WDFirstBus != "" {
+		t.Errorf("unexpected stops after upgrade: %+v", stops)
+	}
+}
+
 type stop struct {
 	code     string
 	lat, lng float64
 }
+
+func TestSyncRetiresRemovedStops(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "A", RoadName: "Road", Description: "Stop A", Latitude: 1.3, Longitude: 103.8},
+		{BusStopCode: "B", RoadName: "Road", Description: "Stop B", Latitude: 1.301, Longitude: 103.8},
+		{BusStopCode: "C", RoadName: "Road", Description: "Stop C", Latitude: 1.302, Longitude: 103.8},
+	})
+
+	// B is renamed, C is decommissioned and D is new.
+	diff := publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "A", RoadName: "Road", Description: "Stop A", Latitude: 1.3, Longitude: 103.8},
+		{BusStopCode: "B", RoadName: "Road", Description: "Stop B2", Latitude: 1.301, Longitude: 103.8},
+		{BusStopCode: "D", RoadName: "Road", Description: "Stop D", Latitude: 1.303, Longitude: 103.8},
+	})
+	if !reflect.DeepEqual(diff, StopDiff{Added: []string{"D"}, Renamed: []string{"B"}, Removed: []string{"C"}}) {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+
+	stop, err := s.GetStop("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop == nil || stop.RetiredAt == nil {
+		t.Fatalf("expected C to be kept as retired, got %+v", stop)
+	}
+
+	codes, err := s.GetAllStopCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codes, []string{"A", "B", "D"}) {
+		t.Errorf("expected retired stop left out of stop codes, got %v", codes)
+	}
+	nearby, err := s.Nearby(1.302, 103.8, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range nearby {
+		if st.Code == "C" {
+			t.Errorf("expected retired stop left out of nearby results")
+		}
+	}
+	found, err := s.SearchStops("stop c", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected retired stop left out of search, got %+v", found)
+	}
+
+	// C comes back into service.
+	diff = publishStops(t, s, []lta.BusStop{
+		{BusStopCode: "A", RoadName: "Road", Description: "Stop A", Latitude: 1.3, Longitude: 103.8},
+		{BusStopCode: "B", RoadName: "Road", Description: "Stop B2", Latitude: 1.301, Longitude: 103.8},
+		{BusStopCode: "C", RoadName: "Road", Description: "Stop C", Latitude: 1.302, Longitude: 103.8},
+		{BusStopCode: "D", RoadName: "Road", Description: "Stop D", Latitude: 1.303, Longitude: 103.8},
+	})
+	if !reflect.DeepEqual(diff, StopDiff{Added: []string{"C"}}) {
+		t.Errorf("expected C reinstated, got %+v", diff)
+	}
+	if stop, _ := s.GetStop("C"); stop == nil || stop.RetiredAt != nil {
+		t.Errorf("expected C back in service, got %+v", stop)
+	}
+}
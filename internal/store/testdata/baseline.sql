@@ -0,0 +1,44 @@
+-- A database as created by the original store.New, before schema
+-- migrations were tracked, with a little data in each table.
+CREATE TABLE IF NOT EXISTS bus_stops (
+	code        TEXT PRIMARY KEY,
+	road_name   TEXT NOT NULL,
+	description TEXT NOT NULL,
+	latitude    REAL NOT NULL,
+	longitude   REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS bus_routes (
+	service_no   TEXT NOT NULL,
+	direction    INTEGER NOT NULL,
+	stop_sequence INTEGER NOT NULL,
+	bus_stop_code TEXT NOT NULL,
+	distance     REAL NOT NULL,
+	PRIMARY KEY (service_no, direction, stop_sequence)
+);
+CREATE INDEX IF NOT EXISTS idx_bus_routes_service ON bus_routes(service_no);
+CREATE TABLE IF NOT EXISTS bus_services (
+	service_no TEXT PRIMARY KEY,
+	operator   TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+	id         TEXT PRIMARY KEY,
+	phrase     TEXT UNIQUE NOT NULL,
+	token      TEXT UNIQUE NOT NULL,
+	config     TEXT NOT NULL DEFAULT '[]',
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_users_phrase ON users(phrase);
+CREATE INDEX IF NOT EXISTS idx_users_token  ON users(token);
+
+INSERT INTO bus_stops VALUES ('53009', 'Bishan Rd', 'Bishan Int', 1.3508, 103.8484);
+INSERT INTO bus_stops VALUES ('53241', 'Bishan St 13', 'Opp Blk 123', 1.3490, 103.8500);
+INSERT INTO meta VALUES ('last_synced', '2024-01-01T00:00:00Z');
+INSERT INTO bus_routes VALUES ('53', 1, 1, '53009', 0);
+INSERT INTO bus_routes VALUES ('53', 1, 2, '53241', 0.6);
+INSERT INTO bus_services VALUES ('53', 'SBST');
+INSERT INTO users VALUES ('u1', 'apple-banana-cherry', 'tok1', '[{"stopNumber":"53009"}]', '2024-01-01T00:00:00Z', '2024-01-01T00:00:00Z');
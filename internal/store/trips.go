@@ -0,0 +1,54 @@
+package store
+
+// DirectTrip is one service and direction that calls at a stop and later
+// at another. Stops counts the hops between them and Distance is the
+// in-route distance in km.
+type DirectTrip struct {
+	ServiceNo    string  `json:"serviceNo"`
+	Direction    int     `json:"direction"`
+	FromSequence int     `json:"fromSequence"`
+	ToSequence   int     `json:"toSequence"`
+	Stops        int     `json:"stops"`
+	Distance     float64 `json:"distance"`
+}
+
+// FindDirectTrips returns every service and direction that goes from one
+// stop to another without a transfer. When a route passes either stop more
+// than once (loops), the shortest ride is kept.
+func (s *Store) FindDirectTrips(fromCode, toCode string) ([]DirectTrip, error) {
+	rows, err := s.data().Query(`
+		SELECT a.service_no, a.direction, a.stop_sequence, b.stop_sequence, b.distance - a.distance
+		FROM bus_routes a
+		JOIN bus_routes b
+		  ON b.service_no = a.service_no
+		 AND b.direction = a.direction
+		 AND b.stop_sequence > a.stop_sequence
+		WHERE a.bus_stop_code = ? AND b.bus_stop_code = ?
+		ORDER BY a.service_no, a.direction, b.stop_sequence - a.stop_sequence
+	`, fromCode, toCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type key struct {
+		service   string
+		direction int
+	}
+	seen := make(map[key]bool)
+	var results []DirectTrip
+	for rows.Next() {
+		var t DirectTrip
+		if err := rows.Scan(&t.ServiceNo, &t.Direction, &t.FromSequence, &t.ToSequence, &t.Distance); err != nil {
+			return nil, err
+		}
+		k := key{t.ServiceNo, t.Direction}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		t.Stops = t.ToSequence - t.FromSequence
+		results = append(results, t)
+	}
+	return results, rows.Err()
+}
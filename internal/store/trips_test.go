@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+func TestFindDirectTrips(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	routes := []lta.BusRoute{
+		// 10 runs A → B in direction 1, and B → A in direction 2.
+		{ServiceNo: "10", Direction: 1, StopSequence: 1, BusStopCode: "A", Distance: 0},
+		{ServiceNo: "10", Direction: 1, StopSequence: 2, BusStopCode: "X", Distance: 0.8},
+		{ServiceNo: "10", Direction: 1, StopSequence: 3, BusStopCode: "B", Distance: 1.5},
+		{ServiceNo: "10", Direction: 2, StopSequence: 1, BusStopCode: "B", Distance: 0},
+		{ServiceNo: "10", Direction: 2, StopSequence: 2, BusStopCode: "A", Distance: 1.4},
+		// 20 is a loop passing A twice; the later boarding is the shorter ride.
+		{ServiceNo: "20", Direction: 1, StopSequence: 1, BusStopCode: "A", Distance: 0},
+		{ServiceNo: "20", Direction: 1, StopSequence: 2, BusStopCode: "Y", Distance: 2},
+		{ServiceNo: "20", Direction: 1, StopSequence: 3, BusStopCode: "A", Distance: 4},
+		{ServiceNo: "20", Direction: 1, StopSequence: 4, BusStopCode: "B", Distance: 5},
+		// 30 only passes B.
+		{ServiceNo: "30", Direction: 1, StopSequence: 1, BusStopCode: "B", Distance: 0},
+	}
+	publishRoutes(t, s, routes)
+
+	trips, err := s.FindDirectTrips("A", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trips) != 2 {
+		t.Fatalf("expected 2 trips, got %d: %+v", len(trips), trips)
+	}
+
+	byService := map[string]DirectTrip{}
+	for _, tr := range trips {
+		byService[tr.ServiceNo] = tr
+	}
+	if tr := byService["10"]; tr.Direction != 1 || tr.Stops != 2 || tr.Distance != 1.5 {
+		t.Errorf("unexpected trip on 10: %+v", tr)
+	}
+	if tr := byService["20"]; tr.Stops != 1 || tr.FromSequence != 3 || tr.Distance != 1 {
+		t.Errorf("expected shortest ride on 20, got %+v", tr)
+	}
+
+	none, err := s.FindDirectTrips("X", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Errorf("expected no trips from X to A, got %+v", none)
+	}
+}
@@ -0,0 +1,66 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+)
+
+// DefaultMaxDrop is the largest fraction of stops, route rows or service
+// directions a sync may lose before it is refused.
+const DefaultMaxDrop = 0.2
+
+// ErrSyncRejected marks a fetched dataset that looks truncated, e.g. after
+// an upstream hiccup. Nothing is written and the previous data stays live.
+var ErrSyncRejected = errors.New("sync rejected")
+
+// dataset is everything one sync fetches from LTA.
+type dataset struct {
+	stops    []lta.BusStop
+	routes   []lta.BusRoute
+	services []lta.BusService
+}
+
+// checkDataset refuses a dataset that is much smaller than the stored one,
+// or in which a service still listed in BusServices has lost all its stops.
+func (sy *Syncer) checkDataset(ds dataset) error {
+	prev, err := sy.store.DatasetSize()
+	if err != nil {
+		return err
+	}
+	for _, c := range []struct {
+		name      string
+		prev, cur int
+	}{
+		{"bus stops", prev.Stops, len(ds.stops)},
+		{"bus routes", prev.Routes, len(ds.routes)},
+		{"bus services", prev.Services, len(ds.services)},
+	} {
+		if c.prev > 0 && float64(c.cur) < float64(c.prev)*(1-sy.maxDrop) {
+			return fmt.Errorf("%w: %s dropped from %d to %d, more than %.0f%%",
+				ErrSyncRejected, c.name, c.prev, c.cur, sy.maxDrop*100)
+		}
+	}
+
+	routed, err := sy.store.RoutedServices()
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool, len(ds.services))
+	for _, svc := range ds.services {
+		listed[svc.ServiceNo] = true
+	}
+	hasStops := make(map[string]bool)
+	for _, r := range ds.routes {
+		hasStops[r.ServiceNo] = true
+	}
+	// A service withdrawn by LTA leaves BusServices too; one that is still
+	// listed but has no route stops points to a truncated BusRoutes.
+	for _, no := range routed {
+		if listed[no] && !hasStops[no] {
+			return fmt.Errorf("%w: service %s lost all its stops", ErrSyncRejected, no)
+		}
+	}
+	return nil
+}
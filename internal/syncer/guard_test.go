@@ -0,0 +1,115 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/store"
+)
+
+// networkClient serves n stops and two services calling at all of them.
+func networkClient(n int) *mockClient {
+	c := &mockClient{
+		services: []lta.BusService{
+			{ServiceNo: "10", Operator: "SBST", Direction: 1},
+			{ServiceNo: "14", Operator: "SBST", Direction: 1},
+		},
+	}
+	for i := range n {
+		code := fmt.Sprintf("%05d", i)
+		c.stops = append(c.stops, lta.BusStop{BusStopCode: code, Latitude: 1.3, Longitude: 103.8})
+		for _, svc := range []string{"10", "14"} {
+			c.routes = append(c.routes, lta.BusRoute{ServiceNo: svc, Direction: 1, StopSequence: i + 1, BusStopCode: code})
+		}
+	}
+	return c
+}
+
+func TestSyncNowRejectsTruncatedData(t *testing.T) {
+	tests := []struct {
+		name     string
+		truncate func(c *mockClient)
+	}{
+		{"routes cut short", func(c *mockClient) { c.routes = c.routes[:50] }},
+		{"stops cut short", func(c *mockClient) { c.stops = c.stops[:10] }},
+		{"service loses all its stops", func(c *mockClient) {
+			var kept []lta.BusRoute
+			for _, r := range c.routes {
+				if r.ServiceNo != "14" {
+					kept = append(kept, r)
+				}
+			}
+			// Pad with extra stops for 10 so the row count alone passes.
+			for i := range len(c.routes) - len(kept) {
+				kept = append(kept, lta.BusRoute{ServiceNo: "10", Direction: 2, StopSequence: i + 1, BusStopCode: "00000"})
+			}
+			c.routes = kept
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := store.New(":memory:")
+			if err != nil {
+				t.Fatalf("failed to open store: %v", err)
+			}
+			defer s.Close()
+
+			client := networkClient(100)
+			syncer := New(s, client)
+			if err := syncer.SyncNow(context.Background()); err != nil {
+				t.Fatalf("SyncNow failed: %v", err)
+			}
+			before, err := s.DatasetSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.truncate(client)
+			if err := syncer.SyncNow(context.Background()); !errors.Is(err, ErrSyncRejected) {
+				t.Fatalf("expected ErrSyncRejected, got %v", err)
+			}
+
+			after, err := s.DatasetSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after != before {
+				t.Errorf("expected the previous dataset to stay live, had %+v, now %+v", before, after)
+			}
+			runs, err := s.SyncRuns(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != 1 || runs[0].Status != store.SyncFailed || runs[0].Error == "" {
+				t.Errorf("expected the rejected run recorded as failed, got %+v", runs)
+			}
+		})
+	}
+}
+
+func TestSyncNowAllowsWithdrawnService(t *testing.T) {
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	client := networkClient(100)
+	syncer := New(s, client, WithMaxDrop(0.6))
+	if err := syncer.SyncNow(context.Background()); err != nil {
+		t.Fatalf("SyncNow failed: %v", err)
+	}
+
+	// LTA withdraws 14 entirely: it leaves BusServices as well as BusRoutes.
+	client.services = client.services[:1]
+	client.routes = client.routes[:0:0]
+	for i := range 100 {
+		client.routes = append(client.routes, lta.BusRoute{ServiceNo: "10", Direction: 1, StopSequence: i + 1, BusStopCode: fmt.Sprintf("%05d", i)})
+	}
+	if err := syncer.SyncNow(context.Background()); err != nil {
+		t.Fatalf("expected a withdrawn service to sync, got %v", err)
+	}
+}
@@ -12,21 +12,42 @@ import (
 type LTAClient interface {
 	GetBusStops(ctx context.Context, skip int) (*lta.Response[lta.BusStop], error)
 	GetBusRoutes(ctx context.Context, skip int) (*lta.Response[lta.BusRoute], error)
-	GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*lta.BusArrival, error)
+	GetBusServices(ctx context.Context, skip int) (*lta.Response[lta.BusService], error)
 }
 
 type Syncer struct {
-	store  *store.Store
-	client LTAClient
+	store   *store.Store
+	client  LTAClient
+	maxDrop float64
 }
 
-func New(s *store.Store, c LTAClient) *Syncer {
-	return &Syncer{store: s, client: c}
+// Option configures a Syncer.
+type Option func(*Syncer)
+
+// WithMaxDrop sets the largest fraction, between 0 and 1, by which stops,
+// route rows or service directions may shrink in one sync before it is
+// rejected.
+func WithMaxDrop(fraction float64) Option {
+	return func(sy *Syncer) { sy.maxDrop = fraction }
+}
+
+func New(s *store.Store, c LTAClient, opts ...Option) *Syncer {
+	sy := &Syncer{store: s, client: c, maxDrop: DefaultMaxDrop}
+	for _, opt := range opts {
+		opt(sy)
+	}
+	return sy
 }
 
 func (sy *Syncer) Run(ctx context.Context) {
 	interval := 7 * 24 * time.Hour
 
+	if n, err := sy.store.FailInterruptedSyncRuns(); err != nil {
+		slog.Error("Failed to close out interrupted sync runs", "error", err)
+	} else if n > 0 {
+		slog.Warn("Marked interrupted sync runs as failed", "count", n)
+	}
+
 	last, err := sy.store.LastSynced()
 	if err != nil {
 		slog.Error("Failed to check last synced time", "error", err)
@@ -57,126 +78,140 @@ func (sy *Syncer) Run(ctx context.Context) {
 	}
 }
 
+// SyncNow fetches stops, routes and services from LTA and replaces the
+// stored copies, recording the run and what changed in the sync log.
 func (sy *Syncer) SyncNow(ctx context.Context) error {
-	slog.Info("Syncing bus stops from LTA")
-	var all []lta.BusStop
+	// A first sync has nothing to compare against, so its changes aren't
+	// worth logging.
+	last, err := sy.store.LastSynced()
+	if err != nil {
+		return err
+	}
+	runID, err := sy.store.StartSyncRun()
+	if err != nil {
+		slog.Error("Failed to record sync run", "error", err)
+		return err
+	}
 
-	for skip := 0; ; skip += 500 {
-		res, err := sy.client.GetBusStops(ctx, skip)
-		if err != nil {
-			slog.Error("Failed to fetch bus stops", "skip", skip, "error", err)
-			return err
-		}
+	var result store.SyncResult
+	err = sy.sync(ctx, &result)
+	if last.IsZero() {
+		result.Changes = nil
+	}
+	if ferr := sy.store.FinishSyncRun(runID, result, err); ferr != nil {
+		slog.Error("Failed to record sync run result", "run", runID, "error", ferr)
+	}
+	return err
+}
 
-		all = append(all, res.Value...)
+func (sy *Syncer) sync(ctx context.Context, result *store.SyncResult) error {
+	// Sync traffic must never starve user-facing arrival lookups.
+	ctx = lta.WithPriority(ctx, lta.PriorityBackground)
 
-		if len(res.Value) < 500 {
-			break
-		}
+	// Everything is fetched and checked before anything is written, so a
+	// failed or truncated download leaves the previous dataset live.
+	ds, err := sy.fetch(ctx)
+	if err != nil {
+		return err
 	}
-
-	if err := sy.store.Sync(all); err != nil {
-		slog.Error("Failed to sync bus stops to store", "error", err)
+	if err := sy.checkDataset(ds); err != nil {
+		slog.Error("Refusing to apply LTA dataset", "error", err)
 		return err
 	}
 
-	slog.Info("Bus stops synced", "count", len(all))
+	// The new data is written to a fresh dataset and published in one step,
+	// so readers never see new stops with old routes.
+	build, err := sy.store.BeginDataset()
+	if err != nil {
+		slog.Error("Failed to start dataset", "error", err)
+		return err
+	}
+	defer build.Discard()
 
-	slog.Info("Syncing bus routes from LTA")
-	var allRoutes []lta.BusRoute
-	for skip := 0; ; skip += 500 {
-		res, err := sy.client.GetBusRoutes(ctx, skip)
-		if err != nil {
-			slog.Error("Failed to fetch bus routes", "skip", skip, "error", err)
-			return err
-		}
-		allRoutes = append(allRoutes, res.Value...)
-		if len(res.Value) < 500 {
-			break
-		}
+	diff, err := build.Sync(ds.stops)
+	if err != nil {
+		slog.Error("Failed to sync bus stops to store", "error", err)
+		return err
+	}
+	slog.Info("Bus stops synced", "count", len(ds.stops), "added", len(diff.Added),
+		"moved", len(diff.Moved), "renamed", len(diff.Renamed), "removed", len(diff.Removed))
+	if len(diff.Removed) > 0 {
+		slog.Info("Retired bus stops", "codes", diff.Removed)
 	}
 
-	if err := sy.store.SyncRoutes(allRoutes); err != nil {
+	routeChanges, err := build.SyncRoutes(ds.routes)
+	if err != nil {
 		slog.Error("Failed to sync bus routes to store", "error", err)
 		return err
 	}
+	slog.Info("Bus routes synced", "count", len(ds.routes), "changes", len(routeChanges))
 
-	slog.Info("Bus routes synced", "count", len(allRoutes))
+	if err := build.SyncServices(ds.services); err != nil {
+		slog.Error("Failed to sync bus services to store", "error", err)
+		return err
+	}
+	slog.Info("Bus services synced", "count", len(ds.services))
 
-	if err := sy.store.SeedServiceOperators(); err != nil {
+	// Routed services missing from BusServices still get a row so they show
+	// up in search; their operator is filled in from live arrivals.
+	if err := build.SeedServiceOperators(); err != nil {
 		slog.Error("Failed to seed service operators", "error", err)
 	}
 
-	sy.syncOperators(ctx)
+	if err := build.Publish(); err != nil {
+		slog.Error("Failed to publish dataset", "version", build.Version(), "error", err)
+		return err
+	}
+	slog.Info("Published LTA dataset", "version", build.Version())
 
+	result.Stops = len(ds.stops)
+	result.Routes = len(ds.routes)
+	result.Services = len(ds.services)
+	result.Changes = append(diff.Changes(), routeChanges...)
 	return nil
 }
 
-func (sy *Syncer) queryStopsForOperators(ctx context.Context, stops map[string][]string) int {
-	var synced int
-	for stopCode := range stops {
-		arrivals, err := sy.client.GetBusArrival(ctx, stopCode, "")
+func (sy *Syncer) fetch(ctx context.Context) (dataset, error) {
+	var ds dataset
+
+	slog.Info("Fetching bus stops from LTA")
+	for skip := 0; ; skip += 500 {
+		res, err := sy.client.GetBusStops(ctx, skip)
 		if err != nil {
-			slog.Warn("Failed to fetch arrivals for operator sync", "stopCode", stopCode, "error", err)
-			continue
+			slog.Error("Failed to fetch bus stops", "skip", skip, "error", err)
+			return ds, err
 		}
-		for _, svc := range arrivals.Services {
-			if svc.Operator != "" {
-				if err := sy.store.UpsertServiceOperator(svc.ServiceNumber, svc.Operator); err != nil {
-					slog.Warn("Failed to upsert operator", "serviceNo", svc.ServiceNumber, "error", err)
-				}
-			}
+		ds.stops = append(ds.stops, res.Value...)
+		if len(res.Value) < 500 {
+			break
 		}
-		synced++
-	}
-	return synced
-}
-
-func (sy *Syncer) syncOperators(ctx context.Context) {
-	refs, err := sy.store.DistinctServiceStops()
-	if err != nil {
-		slog.Error("Failed to get distinct services for operator sync", "error", err)
-		return
-	}
-
-	// Group by stop code to minimize API calls.
-	byStop := make(map[string][]string)
-	queriedStops := make(map[string]bool)
-	for _, r := range refs {
-		byStop[r.StopCode] = append(byStop[r.StopCode], r.ServiceNo)
-		queriedStops[r.StopCode] = true
 	}
 
-	slog.Info("Syncing bus operators", "stops", len(byStop), "services", len(refs))
-	synced := sy.queryStopsForOperators(ctx, byStop)
-	slog.Info("Bus operators first pass", "stops_queried", synced)
-
-	// Second pass: services still missing operators, try different stops.
-	missing, err := sy.store.MissingOperatorServices()
-	if err != nil {
-		slog.Error("Failed to get missing operator services", "error", err)
-		return
-	}
-	if len(missing) == 0 {
-		return
+	slog.Info("Fetching bus routes from LTA")
+	for skip := 0; ; skip += 500 {
+		res, err := sy.client.GetBusRoutes(ctx, skip)
+		if err != nil {
+			slog.Error("Failed to fetch bus routes", "skip", skip, "error", err)
+			return ds, err
+		}
+		ds.routes = append(ds.routes, res.Value...)
+		if len(res.Value) < 500 {
+			break
+		}
 	}
 
-	// Second pass: use the other direction's first stop (direction 2, stop 1).
-	altRefs, err := sy.store.AlternateServiceStops()
-	if err != nil {
-		slog.Error("Failed to get alternate stops", "error", err)
-		return
-	}
-	byStop = make(map[string][]string)
-	for _, r := range altRefs {
-		if !queriedStops[r.StopCode] {
-			byStop[r.StopCode] = append(byStop[r.StopCode], r.ServiceNo)
+	slog.Info("Fetching bus services from LTA")
+	for skip := 0; ; skip += 500 {
+		res, err := sy.client.GetBusServices(ctx, skip)
+		if err != nil {
+			slog.Error("Failed to fetch bus services", "skip", skip, "error", err)
+			return ds, err
+		}
+		ds.services = append(ds.services, res.Value...)
+		if len(res.Value) < 500 {
+			break
 		}
 	}
 
-	if len(byStop) > 0 {
-		slog.Info("Syncing bus operators (retry)", "stops", len(byStop), "services", len(missing))
-		synced = sy.queryStopsForOperators(ctx, byStop)
-		slog.Info("Bus operators second pass", "stops_queried", synced)
-	}
+	return ds, nil
 }
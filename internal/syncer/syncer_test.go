@@ -12,7 +12,7 @@ import (
 type mockClient struct {
 	stops    []lta.BusStop
 	routes   []lta.BusRoute
-	arrivals map[string]*lta.BusArrival // key: stopCode
+	services []lta.BusService
 }
 
 func (m *mockClient) GetBusRoutes(ctx context.Context, skip int) (*lta.Response[lta.BusRoute], error) {
@@ -37,11 +37,15 @@ func (m *mockClient) GetBusStops(ctx context.Context, skip int) (*lta.Response[l
 	return &lta.Response[lta.BusStop]{Value: m.stops[skip:end]}, nil
 }
 
-func (m *mockClient) GetBusArrival(ctx context.Context, busStopCode, serviceNumber string) (*lta.BusArrival, error) {
-	if a, ok := m.arrivals[busStopCode]; ok {
-		return a, nil
+func (m *mockClient) GetBusServices(ctx context.Context, skip int) (*lta.Response[lta.BusService], error) {
+	if skip >= len(m.services) {
+		return &lta.Response[lta.BusService]{Value: []lta.BusService{}}, nil
 	}
-	return &lta.BusArrival{BusStopCode: busStopCode}, nil
+	end := skip + 500
+	if end > len(m.services) {
+		end = len(m.services)
+	}
+	return &lta.Response[lta.BusService]{Value: m.services[skip:end]}, nil
 }
 
 func TestSyncNow(t *testing.T) {
@@ -131,14 +135,9 @@ func TestSyncNowOperators(t *testing.T) {
 			{ServiceNo: "5", Direction: 1, StopSequence: 1, BusStopCode: "S1", Distance: 0},
 			{ServiceNo: "51", Direction: 1, StopSequence: 1, BusStopCode: "S1", Distance: 0},
 		},
-		arrivals: map[string]*lta.BusArrival{
-			"S1": {
-				BusStopCode: "S1",
-				Services: []lta.Service{
-					{ServiceNumber: "5", Operator: "SBST"},
-					{ServiceNumber: "51", Operator: "SMRT"},
-				},
-			},
+		services: []lta.BusService{
+			{ServiceNo: "5", Operator: "SBST", Direction: 1, Category: "TRUNK", OriginCode: "S1", DestinationCode: "S1"},
+			{ServiceNo: "51", Operator: "SMRT", Direction: 1, Category: "TRUNK", OriginCode: "S1", DestinationCode: "S1"},
 		},
 	}
 
@@ -166,3 +165,59 @@ func TestSyncNowOperators(t *testing.T) {
 		t.Errorf("expected SMRT for service 51, got %q", ops["51"])
 	}
 }
+
+func TestSyncNowRecordsChanges(t *testing.T) {
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	client := &mockClient{
+		stops: []lta.BusStop{
+			{BusStopCode: "S1", RoadName: "Road 1", Description: "Desc 1", Latitude: 1.3, Longitude: 103.8},
+			{BusStopCode: "S2", RoadName: "Road 2", Description: "Desc 2", Latitude: 1.31, Longitude: 103.81},
+		},
+		routes: []lta.BusRoute{
+			{ServiceNo: "14", Direction: 1, StopSequence: 1, BusStopCode: "S1"},
+			{ServiceNo: "14", Direction: 1, StopSequence: 2, BusStopCode: "S2"},
+		},
+	}
+	// The tiny dataset shrinks by half, past the default drop guard.
+	syncer := New(s, client, WithMaxDrop(1))
+	start := time.Now().Add(-time.Second)
+	if err := syncer.SyncNow(context.Background()); err != nil {
+		t.Fatalf("SyncNow failed: %v", err)
+	}
+
+	// LTA retires S2 and service 14 stops calling there.
+	client.stops = client.stops[:1]
+	client.routes = client.routes[:1]
+	if err := syncer.SyncNow(context.Background()); err != nil {
+		t.Fatalf("SyncNow failed: %v", err)
+	}
+
+	runs, err := s.SyncRuns(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", runs)
+	}
+	if runs[1].Status != store.SyncOK || runs[1].Stops != 2 || runs[1].Changes != 0 {
+		t.Errorf("expected the initial run to log no changes, got %+v", runs[1])
+	}
+	if runs[0].Status != store.SyncOK || runs[0].Stops != 1 || runs[0].Routes != 1 || runs[0].Changes != 2 {
+		t.Errorf("unexpected second run %+v", runs[0])
+	}
+
+	changes, err := s.ChangesSince(start, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 ||
+		changes[0].Kind != store.ChangeStopRemoved || changes[0].StopCode != "S2" ||
+		changes[1].Kind != store.ChangeRouteStopRemoved || changes[1].ServiceNo != "14" || changes[1].StopCode != "S2" {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
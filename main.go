@@ -6,6 +6,7 @@ import (
 	"embed"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
 	"io/fs"
@@ -13,12 +14,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aattwwss/yabatasg/internal/handler"
 	"github.com/aattwwss/yabatasg/internal/lta"
+	"github.com/aattwwss/yabatasg/internal/places"
+	"github.com/aattwwss/yabatasg/internal/planner"
 	"github.com/aattwwss/yabatasg/internal/store"
 	"github.com/aattwwss/yabatasg/internal/syncer"
 	"github.com/joho/godotenv"
@@ -31,6 +35,10 @@ var staticFiles embed.FS
 var templateFiles embed.FS
 
 func main() {
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "print pending schema migrations for DB_PATH and exit")
+	rollbackDataset := flag.Bool("rollback-dataset", false, "make the previous LTA dataset in DB_PATH live and exit; a running server picks it up on restart")
+	flag.Parse()
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	slog.SetDefault(logger)
 
@@ -49,6 +57,21 @@ func main() {
 		dbPath = "data/yabatasg.db"
 	}
 
+	if *migrateDryRun {
+		pending, err := store.PendingMigrations(dbPath)
+		if err != nil {
+			slog.Error("Failed to read schema version", "path", dbPath, "error", err)
+			os.Exit(1)
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, m := range pending {
+			fmt.Printf("%d %s\n", m.Version, m.Name)
+		}
+		return
+	}
+
 	stopsStore, err := store.New(dbPath)
 	if err != nil {
 		slog.Error("Failed to open SQLite store", "path", dbPath, "error", err)
@@ -56,9 +79,21 @@ func main() {
 	}
 	defer stopsStore.Close()
 
+	if *rollbackDataset {
+		v, err := stopsStore.RollbackDataset()
+		if err != nil {
+			slog.Error("Failed to roll back dataset", "path", dbPath, "error", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Dataset %d is live\n", v.Version)
+		return
+	}
+
 	indexTmpl, err := template.New("index.html").Funcs(template.FuncMap{
 		"formatArrival": handler.FormatArrival,
 		"arrivalClass":  handler.ArrivalClass,
+		"formatAsOf":    handler.FormatAsOf,
+		"formatDate":    handler.FormatDate,
 	}).ParseFS(templateFiles, "templates/index.html")
 	if err != nil {
 		slog.Error("Template parsing failed", "error", err)
@@ -88,12 +123,55 @@ func main() {
 		JSONLD:        handler.BuildHomeJSONLD(),
 	}
 
-	ltaClient := lta.New(os.Getenv("LTA_ACCESS_KEY"), os.Getenv("LTA_API_HOST"))
-	stopsSyncer := syncer.New(stopsStore, ltaClient)
+	var ltaOpts []lta.Option
+	if v := os.Getenv("ARRIVAL_STALE_GRACE"); v != "" {
+		grace, err := time.ParseDuration(v)
+		if err != nil {
+			slog.Error("Invalid ARRIVAL_STALE_GRACE", "value", v, "error", err)
+			os.Exit(1)
+		}
+		ltaOpts = append(ltaOpts, lta.WithStaleGrace(grace))
+	}
+	if v := os.Getenv("ARRIVAL_CACHE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			slog.Error("Invalid ARRIVAL_CACHE_SIZE", "value", v)
+			os.Exit(1)
+		}
+		ltaOpts = append(ltaOpts, lta.WithCacheSize(size))
+	}
+	if v := os.Getenv("LTA_RATE_LIMIT"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			slog.Error("Invalid LTA_RATE_LIMIT", "value", v)
+			os.Exit(1)
+		}
+		burst := int(rate * 2)
+		if v := os.Getenv("LTA_RATE_BURST"); v != "" {
+			if burst, err = strconv.Atoi(v); err != nil || burst < 1 {
+				slog.Error("Invalid LTA_RATE_BURST", "value", v)
+				os.Exit(1)
+			}
+		}
+		ltaOpts = append(ltaOpts, lta.WithRateLimit(rate, burst))
+	}
+
+	ltaClient := lta.New(os.Getenv("LTA_ACCESS_KEY"), os.Getenv("LTA_API_HOST"), ltaOpts...)
+	var syncOpts []syncer.Option
+	if v := os.Getenv("SYNC_MAX_DROP_PERCENT"); v != "" {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			slog.Error("Invalid SYNC_MAX_DROP_PERCENT", "value", v)
+			os.Exit(1)
+		}
+		syncOpts = append(syncOpts, syncer.WithMaxDrop(pct/100))
+	}
+	stopsSyncer := syncer.New(stopsStore, ltaClient, syncOpts...)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go stopsSyncer.Run(ctx)
+	go ltaClient.RunJanitor(ctx)
 
 	mux := http.NewServeMux()
 
@@ -181,13 +259,49 @@ func main() {
 	nearbyHandler := handler.NewNearby(stopsStore)
 	mux.Handle("GET /api/v1/stops/nearby", corsMiddleware(nearbyHandler))
 
+	nearbyArrivalsHandler := handler.NewNearbyArrivals(stopsStore, ltaClient)
+	mux.Handle("GET /api/v1/nearby/arrivals", corsMiddleware(nearbyArrivalsHandler))
+
+	stopSearchHandler := handler.NewStopSearch(stopsStore)
+	mux.Handle("GET /api/v1/stops/search", corsMiddleware(stopSearchHandler))
+
+	gazetteer, err := places.Load()
+	if err != nil {
+		slog.Error("Failed to load places gazetteer", "error", err)
+		os.Exit(1)
+	}
+	searchHandler := handler.NewSearch(stopsStore, gazetteer)
+	mux.Handle("GET /api/v1/search", corsMiddleware(searchHandler))
+
 	stopDetailHandler := handler.NewStopDetail(ltaClient, stopsStore)
 	mux.Handle("GET /api/v1/stops/{code}/arrivals", corsMiddleware(stopDetailHandler))
 
+	stopServicesHandler := handler.NewStopServices(stopsStore)
+	mux.Handle("GET /api/v1/stops/{code}/services", corsMiddleware(stopServicesHandler))
+
+	stopDetailV2Handler := handler.NewStopDetailV2(ltaClient)
+	mux.Handle("GET /api/v2/stops/{code}/arrivals", corsMiddleware(stopDetailV2Handler))
+
+	arrivalStream := handler.NewArrivalStream(ltaClient)
+	mux.Handle("GET /api/v1/stops/{code}/arrivals/stream", corsMiddleware(arrivalStream))
+
+	batchHandler := handler.NewBatchArrivals(ltaClient)
+	mux.Handle("POST /api/v1/arrivals/batch", corsMiddleware(batchHandler))
+
 	serviceHandler := handler.NewService(stopsStore)
 	mux.Handle("GET /api/v1/services/search", corsMiddleware(http.HandlerFunc(serviceHandler.Search)))
+	mux.Handle("GET /api/v1/services/{no}", corsMiddleware(http.HandlerFunc(serviceHandler.Info)))
 	mux.Handle("GET /api/v1/services/{no}/stops", corsMiddleware(http.HandlerFunc(serviceHandler.Stops)))
 
+	positionsHandler := handler.NewServicePositions(stopsStore, ltaClient)
+	mux.Handle("GET /api/v1/services/{no}/positions", corsMiddleware(positionsHandler))
+
+	tripsHandler := handler.NewTrips(stopsStore, ltaClient)
+	mux.Handle("GET /api/v1/trips", corsMiddleware(tripsHandler))
+
+	planHandler := handler.NewPlan(planner.New(stopsStore, ltaClient))
+	mux.Handle("GET /api/v1/plan", corsMiddleware(planHandler))
+
 	mux.HandleFunc("GET /api/v1/stops/{code}", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		code := r.PathValue("code")
@@ -210,6 +324,9 @@ func main() {
 		json.NewEncoder(w).Encode(stop)
 	})))
 
+	healthHandler := handler.NewHealth(ltaClient)
+	mux.Handle("GET /api/v1/health", corsMiddleware(healthHandler))
+
 	authHandler := handler.NewAuth(stopsStore)
 	mux.Handle("POST /api/v1/auth/register", corsMiddleware(http.HandlerFunc(authHandler.Register)))
 	mux.Handle("POST /api/v1/auth/link", corsMiddleware(http.HandlerFunc(authHandler.Link)))
@@ -220,6 +337,18 @@ func main() {
 	mux.Handle("PUT /api/v1/config", corsMiddleware(http.HandlerFunc(configHandler.Put)))
 	mux.Handle("DELETE /api/v1/config", corsMiddleware(http.HandlerFunc(configHandler.Delete)))
 
+	syncLogHandler := handler.NewSyncLog(stopsStore)
+	mux.Handle("GET /api/v1/sync/runs", corsMiddleware(http.HandlerFunc(syncLogHandler.Runs)))
+	mux.Handle("GET /api/v1/changes", corsMiddleware(http.HandlerFunc(syncLogHandler.Changes)))
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		slog.Warn("ADMIN_TOKEN is not set, dataset admin endpoints are disabled")
+	}
+	datasetsHandler := handler.NewDatasets(stopsStore, adminToken)
+	mux.HandleFunc("GET /api/v1/admin/datasets", datasetsHandler.List)
+	mux.HandleFunc("POST /api/v1/admin/datasets/rollback", datasetsHandler.Rollback)
+
 	mux.HandleFunc("POST /api/v1/stops/sync", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		if err := stopsSyncer.SyncNow(r.Context()); err != nil {
@@ -243,6 +372,9 @@ func main() {
 		}),
 	}
 
+	// SSE connections never go idle, so close them when shutdown begins.
+	srv.RegisterOnShutdown(arrivalStream.Close)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -3,6 +3,7 @@ function busApp() {
     const THEME_KEY = 'busAppTheme';
     const POLL_MS = 30000;
     const STALE_MS = 60000;
+    const POSITIONS_MS = 15000;
 
     return {
         groups: [],
@@ -33,6 +34,7 @@ function busApp() {
         editTarget: null,
 
         _stopPollTimer: null,
+        _stopStream: null,
         _toastId: 0,
 
         // nearby
@@ -56,6 +58,10 @@ function busApp() {
         // route map
         _routeMap: null,
         _routeMarkers: [],
+        _busMarkers: [],
+        busPositions: null,
+        _positionsTimer: null,
+        _positionsFor: '',
 
         // nearby map
         _nearbyMap: null,
@@ -188,6 +194,7 @@ function busApp() {
             this.$watch('currentView', val => {
                 // Maps are kept alive — Leaflet doesn't reinitialize cleanly on a reused element.
                 // _renderRouteMap / _renderNearbyMap handle clearing markers and invalidateSize on re-entry.
+                if (val !== 'serviceRoute') this._stopPositions();
             });
         },
 
@@ -195,6 +202,11 @@ function busApp() {
             if (!window.__INITIAL_STATE__) return null;
             const state = window.__INITIAL_STATE__;
             this.ssrConsumed = true;
+            if (state.retired) {
+                // The server-rendered retired-stop page stays as is.
+                delete window.__INITIAL_STATE__;
+                return state.code;
+            }
             if (state.serviceNo) {
                 this.selectedService = state.serviceNo;
                 this.serviceStops = state.stops || [];
@@ -208,6 +220,13 @@ function busApp() {
                 code: state.code,
                 roadName: state.roadName,
                 services: state.services || [],
+                routes: (state.services || []).map(svc => ({
+                    serviceNo: svc.serviceNo, operator: svc.operator, destination: svc.destination || ''
+                })),
+                stale: !!state.stale,
+                asOf: state.asOf || null,
+                schedules: state.schedules || [],
+                nextFirstBus: state.nextFirstBus || '',
                 loading: false,
                 error: ''
             };
@@ -218,12 +237,20 @@ function busApp() {
         },
 
         destroy() {
-            clearInterval(this._stopPollTimer);
+            this._stopStopPolling();
+            this._stopPositions();
             window.removeEventListener('popstate', this._onPopStateBound);
         },
 
         _startStopPolling(code) {
-            clearInterval(this._stopPollTimer);
+            this._stopStopPolling();
+            if (this.selectedStop && !this.selectedStop.routes) {
+                this._loadStopRoutes(code);
+            }
+            if (window.EventSource) {
+                this._startStopStream(code);
+                return;
+            }
             this._stopPollTimer = setInterval(() => {
                 if (this.selectedStop && !this.selectedStop.loading) {
                     this._loadStopDetail(code);
@@ -231,9 +258,44 @@ function busApp() {
             }, POLL_MS);
         },
 
+        _startStopStream(code) {
+            const es = new EventSource(`/api/v1/stops/${code}/arrivals/stream`);
+            this._stopStream = es;
+            es.addEventListener('snapshot', (e) => {
+                if (!this.selectedStop || this.selectedStop.code !== code) return;
+                const data = JSON.parse(e.data);
+                this._applyStopServices(code, data.services || [], data);
+            });
+            es.addEventListener('update', (e) => {
+                if (!this.selectedStop || this.selectedStop.code !== code) return;
+                const data = JSON.parse(e.data);
+                const live = this.selectedStop.services.filter(svc => svc.next1 != null);
+                const byNo = new Map(live.map(svc => [svc.serviceNo, svc]));
+                for (const no of data.removed || []) byNo.delete(no);
+                for (const svc of data.updated || []) byNo.set(svc.serviceNo, svc);
+                const services = [...byNo.values()].sort((a, b) =>
+                    a.serviceNo.localeCompare(b.serviceNo, undefined, { numeric: true }));
+                this._applyStopServices(code, services, data);
+            });
+        },
+
+        _applyStopServices(code, services, meta) {
+            this.selectedStop.services = this._withStopRoutes(services);
+            this.selectedStop.loading = false;
+            this.selectedStop.error = '';
+            this.selectedStop.stale = !!meta.stale;
+            this.selectedStop.asOf = meta.asOf || null;
+            const s = this._findCachedStop(code);
+            if (s) { s.services = services; s.lastFetched = Date.now(); }
+        },
+
         _stopStopPolling() {
             clearInterval(this._stopPollTimer);
             this._stopPollTimer = null;
+            if (this._stopStream) {
+                this._stopStream.close();
+                this._stopStream = null;
+            }
         },
 
         _refresh() {
@@ -659,6 +721,70 @@ function busApp() {
             } else if (latlngs.length === 1) {
                 this._routeMap.setView(latlngs[0], 16);
             }
+
+            if (this._positionsFor !== this.selectedService) {
+                this._startPositions(this.selectedService);
+            } else {
+                this._renderBusMarkers();
+            }
+        },
+
+        // ── Live bus positions ──
+        _startPositions(no) {
+            this._stopPositions();
+            this._positionsFor = no;
+            this._loadPositions(no);
+            this._positionsTimer = setInterval(() => this._loadPositions(no), POSITIONS_MS);
+        },
+
+        _stopPositions() {
+            clearInterval(this._positionsTimer);
+            this._positionsTimer = null;
+            this._positionsFor = '';
+            this.busPositions = null;
+            this._clearBusMarkers();
+        },
+
+        async _loadPositions(no) {
+            try {
+                const r = await fetch(`/api/v1/services/${encodeURIComponent(no)}/positions`);
+                if (!r.ok) throw new Error(`HTTP ${r.status}`);
+                const data = await r.json();
+                if (this._positionsFor !== no) return;
+                this.busPositions = data;
+                this._renderBusMarkers();
+            } catch {
+                // Positions are a nice-to-have; keep showing the last known ones.
+            }
+        },
+
+        _clearBusMarkers() {
+            if (this._routeMap) this._busMarkers.forEach(m => this._routeMap.removeLayer(m));
+            this._busMarkers = [];
+        },
+
+        _renderBusMarkers() {
+            if (typeof L === 'undefined' || !this._routeMap) return;
+            this._clearBusMarkers();
+            const dir = (this.busPositions?.directions || []).find(d => d.direction === this.selectedDirection);
+            if (!dir) return;
+            for (const bus of dir.buses) {
+                const eta = Math.round(bus.etaSeconds / 60);
+                const next = this.serviceStops.find(s => s.stopCode === bus.nextStopCode && s.direction === dir.direction);
+                const nextName = next ? (next.description || next.roadName || next.stopCode) : bus.nextStopCode;
+                const marker = L.marker([bus.latitude, bus.longitude], {
+                    icon: L.divIcon({
+                        className: 'bus-marker',
+                        html: '<i class="fas fa-bus"></i>',
+                        iconSize: [24, 24],
+                        iconAnchor: [12, 12]
+                    }),
+                    zIndexOffset: 500
+                }).addTo(this._routeMap)
+                  .bindPopup(this._escHtml(nextName) + (eta > 0 ? ' in ' + eta + ' min' : ' — arriving'),
+                      { className: 'route-popup', closeButton: false });
+                this._busMarkers.push(marker);
+            }
         },
 
         _highlightStopCard(code) {
@@ -798,6 +924,11 @@ function busApp() {
             if (v <= 8) return 'soon';
             return 'later';
         },
+        formatAsOf(ts) {
+            if (!ts) return '';
+            return new Date(ts).toLocaleTimeString('en-SG', { hour: 'numeric', minute: '2-digit', timeZone: 'Asia/Singapore' });
+        },
+
         formatArrival(v) {
             if (v == null || v < 0) return '--';
             return v + '';
@@ -894,7 +1025,11 @@ function busApp() {
                 const r = await fetch(`/api/v1/stops/${code}/arrivals`);
                 if (!r.ok) throw new Error(`HTTP ${r.status}`);
                 const data = await r.json();
-                this.selectedStop.services = data.services || [];
+                this.selectedStop.services = this._withStopRoutes(data.services || []);
+                this.selectedStop.stale = !!data.stale;
+                this.selectedStop.asOf = data.asOf || null;
+                this.selectedStop.schedules = data.schedules || [];
+                this.selectedStop.nextFirstBus = data.nextFirstBus || '';
                 this.selectedStop.loading = false;
                 // Update the shortcut's cached data so next view shows it instantly.
                 const s = this._findCachedStop(code);
@@ -905,6 +1040,46 @@ function busApp() {
             }
         },
 
+        // Loads every service calling at the stop from the route data, so
+        // services with no live ETA are still listed.
+        async _loadStopRoutes(code) {
+            try {
+                const r = await fetch(`/api/v1/stops/${code}/services`);
+                if (!r.ok) return;
+                const rows = await r.json();
+                if (!this.selectedStop || this.selectedStop.code !== code) return;
+                const routes = [];
+                const seen = new Set();
+                for (const row of rows) {
+                    if (seen.has(row.serviceNo)) continue;
+                    seen.add(row.serviceNo);
+                    routes.push({ serviceNo: row.serviceNo, operator: row.operator, destination: row.destinationName });
+                }
+                this.selectedStop.routes = routes;
+                const live = (this.selectedStop.services || []).filter(svc => svc.next1 != null);
+                this.selectedStop.services = this._withStopRoutes(live);
+            } catch { /* live arrivals still show */ }
+        },
+
+        // Merges live arrivals with the stop's routed services: live entries
+        // gain a destination, and services with no live data are added with
+        // empty ETAs.
+        _withStopRoutes(live) {
+            const routes = this.selectedStop?.routes || [];
+            const dest = new Map(routes.map(rt => [rt.serviceNo, rt.destination]));
+            const out = live.map(svc => ({ ...svc, destination: svc.destination || dest.get(svc.serviceNo) || '' }));
+            const seen = new Set(live.map(svc => svc.serviceNo));
+            for (const rt of routes) {
+                if (seen.has(rt.serviceNo)) continue;
+                out.push({ serviceNo: rt.serviceNo, operator: rt.operator, destination: rt.destination, next1: null, next2: null, next3: null });
+            }
+            return out.sort((a, b) => a.serviceNo.localeCompare(b.serviceNo, undefined, { numeric: true }));
+        },
+
+        stopHasLive(st) {
+            return (st?.services || []).some(svc => svc.next1 != null);
+        },
+
         async addShortcutFromStop(stopCode) {
             this.form.stopNumber = stopCode;
             this.form.name = '';
@@ -975,6 +1150,17 @@ function busApp() {
             return groups;
         },
 
+        // Today's first–last bus at a route stop, from the raw "HHmm" BusRoutes
+        // fields. Days follow Singapore time.
+        busTimesToday(st) {
+            const day = new Date(Date.now() + 8 * 3600e3).getUTCDay();
+            const [first, last] = day === 0 ? [st.sunFirstBus, st.sunLastBus]
+                : day === 6 ? [st.satFirstBus, st.satLastBus]
+                : [st.wdFirstBus, st.wdLastBus];
+            const fmt = (t) => /^\d{4}$/.test(t || '') ? t.slice(0, 2) + ':' + t.slice(2) : '';
+            return fmt(first) && fmt(last) ? fmt(first) + ' – ' + fmt(last) : '';
+        },
+
         getDirectionLabel(dir) {
             const dirStops = this.groupedServiceStops()[dir];
             if (!dirStops || dirStops.length === 0) return 'Direction ' + dir;